@@ -2,10 +2,12 @@ package main
 
 import (
 	"context"
+	"errors"
 	"os"
 	"os/signal"
 	"syscall"
 
+	"github.com/lex-unix/faino/internal/app"
 	"github.com/lex-unix/faino/internal/cli"
 	"github.com/lex-unix/faino/internal/cli/cliutil"
 	"github.com/lex-unix/faino/internal/logging"
@@ -18,8 +20,16 @@ func main() {
 
 	f := cliutil.New()
 	rootCmd := cli.NewRootCmd(ctx, f)
-	if err := rootCmd.Execute(); err != nil {
+	err := rootCmd.Execute()
+	// the plan is printed even if the command failed, as it shows how far it got
+	f.PrintPlan(os.Stdout)
+	if err != nil {
 		logging.Errorf("command failed: %s", err)
+		// one-off commands exit with the status of the remote command
+		var exitErr *app.ExitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.Code)
+		}
 		os.Exit(1)
 	}
 }
@@ -0,0 +1,217 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/lex-unix/faino/internal/command"
+	"github.com/lex-unix/faino/internal/config"
+	"github.com/lex-unix/faino/internal/exec/sshexec"
+	"github.com/lex-unix/faino/internal/logging"
+)
+
+const (
+	// accessoryFilesDir is where files of accessories are uploaded on hosts
+	accessoryFilesDir = "$HOME/.faino/accessories"
+
+	// accessoryLabel marks accessory containers with the accessory name
+	accessoryLabel = "faino.accessory"
+)
+
+// accessory returns the config of the named accessory and an App that manages only
+// the target hosts the accessory runs on.
+func (app *App) accessory(name string) (config.Accessory, *App, error) {
+	cfg := config.Get()
+	acc, ok := cfg.Accessories[name]
+	if !ok {
+		return config.Accessory{}, nil, fmt.Errorf("accessory %s is not configured", name)
+	}
+
+	hosts := acc.Hosts
+	if len(hosts) == 0 {
+		hosts = app.txmanager.Hosts()
+	}
+	accApp, err := app.onHosts(hosts)
+	if err != nil {
+		return config.Accessory{}, nil, err
+	}
+	if accApp == nil {
+		return config.Accessory{}, nil, fmt.Errorf("accessory %s does not run on any of the target hosts", name)
+	}
+	return acc, accApp, nil
+}
+
+// accessoryContainer returns the name of the container running the named accessory.
+func accessoryContainer(name string) string {
+	return fmt.Sprintf("%s-%s", config.Get().Service, name)
+}
+
+// BootAccessory uploads the files of the named accessory and runs its container
+// on the hosts it is pinned to. It fails on hosts where the accessory already exists.
+func (app *App) BootAccessory(ctx context.Context, name string) error {
+	acc, accApp, err := app.accessory(name)
+	if err != nil {
+		return err
+	}
+	container := accessoryContainer(name)
+	acc.Env, err = app.secrets.ResolveMap(ctx, acc.Env)
+	if err != nil {
+		return fmt.Errorf("failed to resolve env of accessory %s: %w", name, err)
+	}
+	for k, v := range acc.Env {
+		if strings.Contains(v, "\n") {
+			return fmt.Errorf("env %s of accessory %s must not contain newlines", k, name)
+		}
+	}
+
+	files := make(map[string][]byte, len(acc.Files))
+	for _, file := range acc.Files {
+		local, _, _ := strings.Cut(file, ":")
+		data, err := os.ReadFile(local)
+		if err != nil {
+			return fmt.Errorf("failed to read file of accessory %s: %w", name, err)
+		}
+		files[local] = data
+	}
+
+	return accApp.txmanager.Execute(ctx, func(ctx context.Context, client sshexec.Service) error {
+		var out bytes.Buffer
+		err := client.Run(ctx, command.ContainerID(container), sshexec.WithStdout(&out))
+		if err != nil {
+			return err
+		}
+		if strings.TrimSpace(out.String()) != "" {
+			return fmt.Errorf("accessory %s already exists on %s, use reboot to recreate it", name, client.Host())
+		}
+
+		for _, file := range acc.Files {
+			local, remote, _ := strings.Cut(file, ":")
+			hostPath := accessoryFilePath(name, remote)
+			err := client.Run(ctx, fmt.Sprintf("mkdir -p %s", path.Dir(hostPath)))
+			if err != nil {
+				return err
+			}
+			err = client.WriteFile(hostPath, files[local])
+			if err != nil {
+				return fmt.Errorf("failed to upload %s to %s: %w", local, client.Host(), err)
+			}
+		}
+
+		if len(acc.Env) > 0 {
+			err := WriteEnvFile(envFilePath(container), acc.Env, true)(ctx, client)
+			if err != nil {
+				return fmt.Errorf("failed to write env file of accessory %s to %s: %w", name, client.Host(), err)
+			}
+		}
+
+		if acc.Network != "" {
+			err := client.Run(ctx, command.CreateNetwork(acc.Network))
+			if err != nil {
+				return err
+			}
+		}
+		err = client.Run(ctx, command.PullImage(acc.Image))
+		if err != nil {
+			return err
+		}
+		err = client.Run(ctx, command.RunAccessory(acc.Image, container, accessoryFlags(name, container, acc), acc.Cmd))
+		if err != nil {
+			return err
+		}
+		logging.InfoHostf(client.Host(), "accessory %s booted", name)
+		return nil
+	})
+}
+
+// RebootAccessory removes the container of the named accessory and boots it again,
+// applying config changes. Volumes are kept.
+func (app *App) RebootAccessory(ctx context.Context, name string) error {
+	if err := app.RemoveAccessory(ctx, name); err != nil {
+		return err
+	}
+	return app.BootAccessory(ctx, name)
+}
+
+func (app *App) StartAccessory(ctx context.Context, name string) error {
+	_, accApp, err := app.accessory(name)
+	if err != nil {
+		return err
+	}
+	return accApp.startContainer(ctx, fixedContainer(accessoryContainer(name)), WaitForRunning)
+}
+
+func (app *App) StopAccessory(ctx context.Context, name string) error {
+	_, accApp, err := app.accessory(name)
+	if err != nil {
+		return err
+	}
+	return accApp.stopContainer(ctx, fixedContainer(accessoryContainer(name)))
+}
+
+// RemoveAccessory removes the container, env file and uploaded files of the named accessory.
+// Volumes are kept.
+func (app *App) RemoveAccessory(ctx context.Context, name string) error {
+	_, accApp, err := app.accessory(name)
+	if err != nil {
+		return err
+	}
+	container := accessoryContainer(name)
+	return accApp.txmanager.Execute(ctx, func(ctx context.Context, client sshexec.Service) error {
+		err := client.Run(ctx, command.RemoveContainer(container))
+		if err != nil {
+			return fmt.Errorf("failed to remove accessory %s on %s: %w", name, client.Host(), err)
+		}
+		err = client.Run(ctx, command.RemoveFile(envFilePath(container)))
+		if err != nil {
+			return err
+		}
+		return client.Run(ctx, fmt.Sprintf("rm -rf %s/%s", accessoryFilesDir, name))
+	})
+}
+
+func (app *App) AccessoryLogs(ctx context.Context, name string, follow bool, lines int, since string) error {
+	_, accApp, err := app.accessory(name)
+	if err != nil {
+		return err
+	}
+	logsCmd := command.ContainerLogs(accessoryContainer(name), follow, lines, since)
+	return accApp.logs(ctx, func(string) string { return logsCmd })
+}
+
+func (app *App) ExecAccessory(ctx context.Context, name string, execCmd string, interactive bool) error {
+	_, accApp, err := app.accessory(name)
+	if err != nil {
+		return err
+	}
+	return accApp.exec(ctx, fixedContainer(accessoryContainer(name)), execCmd, interactive)
+}
+
+func (app *App) ShowAccessoryInfo(ctx context.Context, name string) (map[string]string, error) {
+	_, accApp, err := app.accessory(name)
+	if err != nil {
+		return nil, err
+	}
+	return accApp.showInfo(ctx, accessoryContainer(name))
+}
+
+// accessoryFilePath returns where a file mounted at containerPath in the named accessory is stored on hosts.
+func accessoryFilePath(name, containerPath string) string {
+	return path.Join(accessoryFilesDir, name, containerPath)
+}
+
+// accessoryFlags returns `docker run` flags of the named accessory running as container.
+func accessoryFlags(name, container string, acc config.Accessory) []string {
+	flags := []string{fmt.Sprintf("--label %s=%s", accessoryLabel, name)}
+	if len(acc.Env) > 0 {
+		flags = append(flags, "--env-file "+envFilePath(container))
+	}
+	for _, file := range acc.Files {
+		_, remote, _ := strings.Cut(file, ":")
+		flags = append(flags, fmt.Sprintf("--volume %s:%s:ro", accessoryFilePath(name, remote), remote))
+	}
+	return append(flags, containerFlags(acc.Container)...)
+}
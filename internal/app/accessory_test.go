@@ -0,0 +1,28 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/lex-unix/faino/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccessoryFlags(t *testing.T) {
+	acc := config.Accessory{
+		Image: "postgres:16",
+		Env:   map[string]string{"POSTGRES_USER": "app", "POSTGRES_DB": "app"},
+		Files: []string{"config/postgresql.conf:/etc/postgresql/postgresql.conf"},
+		Container: config.Container{
+			Ports:   []string{"5432:5432"},
+			Volumes: []string{"pgdata:/var/lib/postgresql/data"},
+		},
+	}
+
+	assert.Equal(t, []string{
+		"--label faino.accessory=db",
+		"--env-file $HOME/.faino/env/app-db.env",
+		"--volume $HOME/.faino/accessories/db/etc/postgresql/postgresql.conf:/etc/postgresql/postgresql.conf:ro",
+		"--publish 5432:5432",
+		"--volume pgdata:/var/lib/postgresql/data",
+	}, accessoryFlags("db", "app-db", acc))
+}
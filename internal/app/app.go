@@ -4,12 +4,14 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math/rand"
 	"os"
 	"slices"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/lex-unix/faino/internal/command"
@@ -17,6 +19,7 @@ import (
 	"github.com/lex-unix/faino/internal/exec/localexec"
 	"github.com/lex-unix/faino/internal/exec/sshexec"
 	"github.com/lex-unix/faino/internal/logging"
+	"github.com/lex-unix/faino/internal/secrets"
 	"github.com/lex-unix/faino/internal/stream"
 	"github.com/lex-unix/faino/internal/template"
 	"github.com/lex-unix/faino/internal/txman"
@@ -25,10 +28,18 @@ import (
 type App struct {
 	txmanager txman.Service
 	lexec     localexec.Service
+	secrets   *secrets.Store
 
 	history         []History
 	historySorted   bool
 	historyFilePath string
+	// hostHistory holds the history of every host. Histories record the same deploys,
+	// but a deploy that was rolled back on some hosts only has a different status there.
+	hostHistory map[string][]History
+	// historyDivergence is set if the histories read by LoadHistory differ between hosts
+	historyDivergence *HistoryDivergenceError
+
+	lockDir string
 }
 
 type Option func(*App)
@@ -39,11 +50,20 @@ func WithTxManager(txmanager txman.Service) Option {
 	}
 }
 
+// WithSecrets sets the store that resolves secret references in env and build secrets.
+func WithSecrets(store *secrets.Store) Option {
+	return func(a *App) {
+		a.secrets = store
+	}
+}
+
 func New(lexec localexec.Service, options ...Option) *App {
 	a := &App{
 		lexec:           lexec,
+		secrets:         secrets.New(lexec),
 		historyFilePath: defautlHistoryFilePath,
 		historySorted:   false,
+		lockDir:         defaultLockDir,
 	}
 
 	for _, option := range options {
@@ -70,192 +90,719 @@ type HostOutput struct {
 	Output string
 }
 
-func (app *App) Deploy(ctx context.Context) error {
+// Deploy builds a new version of the app and deploys it to every host while holding the deploy lock.
+func (app *App) Deploy(ctx context.Context, opts DeployOptions) error {
+	return app.withLock(ctx, "deploy", func() error {
+		return app.deploy(ctx, opts)
+	})
+}
+
+func (app *App) deploy(ctx context.Context, opts DeployOptions) error {
 	cfg := config.Get()
 
-	// FIXME: should use commit hash for this
+	startedAt := time.Now()
+	rel, err := app.newRelease(ctx, opts)
+	if err != nil {
+		return err
+	}
+	newVersion := rel.Version
+	if _, ok := cfg.Accessories[newVersion]; ok {
+		return fmt.Errorf("version %s clashes with the container name of accessory %s", newVersion, newVersion)
+	}
+	logging.Infof("deploying version %s", newVersion)
+
+	err = app.loadConsistentHistory(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read history at %s: %w", app.historyFilePath, err)
+	}
+	if canary, ok := app.activeCanary(); ok {
+		return &CanaryActiveError{Canary: canary}
+	}
+
 	currentVersion := app.LatestVersion()
-	newVersion := generateRandomString(10)
-	image := fmt.Sprintf("%s/%s:%s", cfg.Registry.Server, cfg.Image, newVersion)
-	currentContainer := fmt.Sprintf("%s-%s", cfg.Service, currentVersion)
-	newContainer := fmt.Sprintf("%s-%s", cfg.Service, newVersion)
-
-	var cmdout bytes.Buffer
-	// check if builder exists
-	err := app.lexec.Run(ctx, command.ListBuilders(cfg.Build.Builder), localexec.WithStdout(&cmdout))
+	redeploy := currentVersion == newVersion
+	if redeploy {
+		if len(opts.Canary) > 0 {
+			return fmt.Errorf("version %s is already deployed, redeploy it without --canary", newVersion)
+		}
+		// e.g. to pick up changed env or secrets
+		logging.Infof("version %s is already deployed, redeploying it", newVersion)
+	}
+	image := app.imageName(newVersion)
+	hc := hookContext{
+		service:         cfg.Service,
+		version:         newVersion,
+		previousVersion: currentVersion,
+		hosts:           app.txmanager.Hosts(),
+		performer:       rel.Author,
+	}
+	if len(opts.Canary) > 0 {
+		hc.hosts = opts.Canary
+	}
+
+	// secrets are resolved before anything runs so that a missing secret fails the deploy early
+	if err := app.resolveSecrets(ctx); err != nil {
+		return err
+	}
+
+	if err := app.runLocalHooks(ctx, hookPreBuild, cfg.Hooks.PreBuild, hc); err != nil {
+		return err
+	}
+
+	digest, err := app.build(ctx, image)
 	if err != nil {
 		return err
 	}
 
-	// if there is no builder, create it
-	if !strings.Contains(cmdout.String(), cfg.Build.Builder) {
-		logging.Infof("creating new docker builder instance: %s", cfg.Build.Builder)
-		err = app.lexec.Run(ctx, command.CreateBuilder(cfg.Build.Builder, cfg.Build.Driver, cfg.Build.Platform))
-		if err != nil {
+	// without a registry, hosts cannot pull the image, so it is shipped to every
+	// host, including those outside of a canary, which get it on promote
+	if cfg.Registry.Mode == config.RegistryModeNone {
+		if err := app.shipImage(ctx, image); err != nil {
 			return err
 		}
 	}
 
-	env := make([]string, 0)
-	for k, v := range cfg.Secrets {
-		env = append(env, fmt.Sprintf("%s=%s", k, v))
+	if err := app.runLocalHooks(ctx, hookPreDeploy, cfg.Hooks.PreDeploy, hc); err != nil {
+		return err
 	}
 
-	err = app.lexec.Run(ctx, command.BuildImage(image, cfg.Build.Dockerfile, cfg.Build.Platform, cfg.Secrets, cfg.Build.Args), localexec.WithEnv(env))
-	if err != nil {
+	if err := app.ensureProxy(ctx); err != nil {
 		return err
 	}
 
-	// check if proxy is running, start or run it if not
-	err = app.txmanager.Execute(ctx, func(ctx context.Context, client sshexec.Service) error {
-		var out bytes.Buffer
-		err := client.Run(ctx, command.ListRunningContainers(), sshexec.WithStdout(&out))
+	entry := History{
+		Schema:      historySchemaVersion,
+		Version:     newVersion,
+		Commit:      rel.Commit,
+		Branch:      rel.Branch,
+		Message:     rel.Message,
+		Author:      rel.Author,
+		ImageDigest: digest,
+		Status:      HistoryStatusSuccess,
+	}
+
+	if len(opts.Canary) > 0 {
+		err := app.deployCanary(ctx, opts.Canary, entry, hc, startedAt)
+		if err != nil || opts.PromoteAfter == 0 {
+			return err
+		}
+		return app.watchCanary(ctx, opts.PromoteAfter)
+	}
+
+	// the entry is finalized by the first host that gets to the history step,
+	// so that every host records the same timestamp and duration
+	var appendOnce sync.Once
+	var appendHistory txman.Callback
+
+	rollback, err := app.txmanager.BeginTransaction(ctx, func(ctx context.Context, tx txman.Transaction) error {
+		err := app.pushEnv(ctx, tx, newVersion, true)
 		if err != nil {
 			return err
 		}
-		// proxy is running
-		if strings.Contains(out.String(), cfg.Proxy.Container) {
-			return nil
+		err = app.remoteHooks(ctx, tx, hookPreDeploy, cfg.Hooks.PreDeploy, hc)
+		if err != nil {
+			return err
+		}
+		err = app.switchVersion(ctx, tx, newVersion, app.currentVersionOn(tx.Host()))
+		if err != nil {
+			return err
 		}
 
-		out.Reset()
-
-		// check if proxy is stopped
-		err = client.Run(ctx, command.ListAllContainers(), sshexec.WithStdout(&out))
+		appendOnce.Do(func() {
+			entry.Timestamp = time.Now()
+			entry.Duration = entry.Timestamp.Sub(startedAt)
+			appendHistory = app.AppendHistory(entry)
+		})
+		err = tx.Do(ctx, appendHistory, nil)
 		if err != nil {
 			return err
 		}
 
-		// proxy is stopped, start it
-		if strings.Contains(out.String(), cfg.Proxy.Container) {
-			return client.Run(ctx, command.StartContainer(cfg.Proxy.Container))
+		return nil
+	}, rollingOptions(cfg.Deploy.Rolling)...)
+
+	var partialErr *txman.PartialError
+	if errors.As(err, &partialErr) {
+		// failed hosts were rolled back and keep running the current version
+		if redeploy {
+			app.removeRedeployBackups(ctx, newVersion)
 		}
+		err = fmt.Errorf("version %s was deployed only partially: %w", newVersion, err)
+		succeeded := slices.DeleteFunc(app.txmanager.Hosts(), func(host string) bool {
+			_, failed := partialErr.Errs[host]
+			return failed
+		})
+		app.addHostHistory(entry, succeeded...)
+		app.history = append(app.history, entry)
+		app.historySorted = false
+		if historyErr := app.recordPartial(ctx, entry, partialErr); historyErr != nil {
+			return errors.Join(err, historyErr)
+		}
+		return err
+	}
 
-		// proxy container not found, run it
-		err = client.Run(ctx, command.RunProxy(cfg.Proxy.Img, formatFlags("label", cfg.Proxy.Labels), formatArgs(cfg.Proxy.Args)))
+	if err != nil {
+		logging.Info("initiating rollback...")
+		rollbackCtx, rollbackCancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer rollbackCancel()
+		if rollbackErr := rollback(rollbackCtx); rollbackErr != nil {
+			entry.Status = HistoryStatusFailed
+			app.recordOutcome(rollbackCtx, entry, startedAt)
+			return errors.Join(err, rollbackErr)
+		}
+		entry.Status = HistoryStatusRolledBack
+		app.recordOutcome(rollbackCtx, entry, startedAt)
+		return fmt.Errorf("deploy failed and was rolled back: %w", err)
+	}
+
+	app.addHostHistory(entry, app.txmanager.Hosts()...)
+	app.history = append(app.history, entry)
+	app.historySorted = false
+
+	if redeploy {
+		app.removeRedeployBackups(ctx, newVersion)
+	}
+	app.autoPrune(ctx)
+
+	if err := app.runPostHooks(ctx, hookPostDeploy, cfg.Hooks.PostDeploy, hc); err != nil {
+		return fmt.Errorf("version %s was deployed but %w", newVersion, err)
+	}
+
+	return nil
+}
+
+// containerName returns the name of the app container of role r running version.
+// It returns an empty string if version is empty, e.g. before the first deploy.
+func (app *App) containerName(r role, version string) string {
+	if version == "" {
+		return ""
+	}
+	return roleContainerName(config.Get().Service, r.name, version)
+}
+
+// imageName returns the image reference for version. Images that are not pushed
+// to a registry are referenced without the registry server.
+func (app *App) imageName(version string) string {
+	cfg := config.Get()
+	if cfg.Registry.Mode == config.RegistryModeNone {
+		return fmt.Sprintf("%s:%s", cfg.Image, version)
+	}
+	return fmt.Sprintf("%s/%s:%s", cfg.Registry.Server, cfg.Image, version)
+}
+
+// build checks that the builder exists, then builds image. The image is pushed to the
+// registry, or built for the platform of the hosts and loaded locally when there is none.
+// It returns the digest of the image.
+func (app *App) build(ctx context.Context, image string) (string, error) {
+	cfg := config.Get()
+
+	if err := app.checkBuilder(ctx); err != nil {
+		return "", err
+	}
+
+	buildSecrets, err := app.secrets.ResolveMap(ctx, cfg.Secrets)
+	if err != nil {
+		return "", err
+	}
+	env := make([]string, 0)
+	for k, v := range buildSecrets {
+		env = append(env, fmt.Sprintf("%s=%s", k, v))
+	}
+
+	push := cfg.Registry.Mode != config.RegistryModeNone
+	platform := cfg.Build.Platform
+	if !push {
+		platform, err = app.hostPlatform(ctx)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return app.buildImage(ctx, image, platform, push, env)
+}
+
+// ensureProxy checks if proxy is running on every host with a proxied role, and starts or runs it if not.
+// If the app runs on a custom network, the network is created and the proxy joins it.
+func (app *App) ensureProxy(ctx context.Context) error {
+	cfg := config.Get()
+	proxyApp, err := app.onHosts(proxiedHosts(appRoles(cfg)))
+	if err != nil || proxyApp == nil {
+		return err
+	}
+	return proxyApp.txmanager.Execute(ctx, func(ctx context.Context, client sshexec.Service) error {
+		err := app.startProxy(ctx, client)
 		if err != nil {
 			return err
 		}
-		return nil
+		if cfg.Container.Network == "" {
+			return nil
+		}
+		err = client.Run(ctx, command.CreateNetwork(cfg.Container.Network))
+		if err != nil {
+			return err
+		}
+		return client.Run(ctx, command.ConnectNetwork(cfg.Container.Network, cfg.Proxy.Container))
 	})
+}
 
+// startProxy starts or runs the proxy on the host unless it is already running.
+func (app *App) startProxy(ctx context.Context, client sshexec.Service) error {
+	cfg := config.Get()
+	var out bytes.Buffer
+	err := client.Run(ctx, command.ListRunningContainers(), sshexec.WithStdout(&out))
 	if err != nil {
 		return err
 	}
+	// proxy is running
+	if strings.Contains(out.String(), cfg.Proxy.Container) {
+		app.warnProxyDrift(ctx, client)
+		return nil
+	}
 
-	err = app.LoadHistory(ctx)
+	out.Reset()
+
+	// check if proxy is stopped
+	err = client.Run(ctx, command.ListAllContainers(), sshexec.WithStdout(&out))
 	if err != nil {
-		return fmt.Errorf("failed to read history at %s: %w", app.historyFilePath, err)
+		return err
+	}
+
+	if strings.Contains(out.String(), cfg.Proxy.Container) {
+		// proxy is stopped, start it
+		err = client.Run(ctx, command.StartContainer(cfg.Proxy.Container))
+	} else {
+		// proxy container not found, run it
+		err = client.Run(ctx, runProxy(app.proxy()))
+	}
+	if err != nil {
+		return err
 	}
+	return WaitForProxy(app.proxy())(ctx, client)
+}
+
+// buildImage builds image for platform, pushes or loads it and returns the digest of the image.
+func (app *App) buildImage(ctx context.Context, image, platform string, push bool, env []string) (string, error) {
+	cfg := config.Get()
 
-	var envs []string
-	for k, v := range cfg.Env {
-		envs = append(envs, fmt.Sprintf("--env %s=%q", k, v))
+	metadata, err := os.CreateTemp("", "faino-build-*.json")
+	if err != nil {
+		return "", err
 	}
+	metadata.Close()
+	defer os.Remove(metadata.Name())
 
-	rollback, err := app.txmanager.BeginTransaction(ctx, func(ctx context.Context, tx txman.Transaction) error {
-		err := tx.Do(ctx, PullImage(image), nil)
+	buildCmd := command.BuildImage(image, cfg.Build.Builder, cfg.Build.Dockerfile, platform, cfg.Secrets, cfg.Build.Args, metadata.Name(), push)
+	if err := app.lexec.Run(ctx, buildCmd, localexec.WithEnv(env)); err != nil {
+		return "", err
+	}
+
+	data, err := os.ReadFile(metadata.Name())
+	if err != nil {
+		return "", fmt.Errorf("failed to read build metadata: %w", err)
+	}
+	// buildx leaves the file empty if it did not run, e.g. in dry-run mode
+	if len(data) == 0 {
+		return "", nil
+	}
+	var meta struct {
+		Digest string `json:"containerimage.digest"`
+	}
+	if err := json.Unmarshal(data, &meta); err != nil {
+		logging.Warnf("failed to parse build metadata: %s", err)
+	}
+	return meta.Digest, nil
+}
+
+// recordOutcome appends entry for a deploy that did not succeed to history on every host.
+// Failing to record the outcome is logged but not returned.
+func (app *App) recordOutcome(ctx context.Context, entry History, startedAt time.Time) {
+	entry.Timestamp = time.Now()
+	entry.Duration = entry.Timestamp.Sub(startedAt)
+	if err := app.txmanager.Execute(ctx, app.AppendHistory(entry)); err != nil {
+		logging.Warnf("failed to record %s deploy in history: %s", entry.Status, err)
+	}
+}
+
+// containerSpec describes how an app container is run.
+type containerSpec struct {
+	image   string
+	name    string
+	env     []string
+	labels  []string
+	options []string
+	cmd     string
+}
+
+func (spec containerSpec) run() txman.Callback {
+	return RunContainer(spec.image, spec.name, spec.env, spec.labels, spec.options, spec.cmd)
+}
+
+// appContainerSpec returns the spec of the app container of role r running version.
+// Role env and container options are applied on top of the global ones, and only
+// proxied roles get proxy labels and the health check.
+func (app *App) appContainerSpec(r role, version string) containerSpec {
+	cfg := config.Get()
+	spec := containerSpec{
+		image:   app.imageName(version),
+		name:    app.containerName(r, version),
+		env:     []string{"--env-file " + envFilePath(app.containerName(r, version))},
+		options: append(containerFlags(cfg.Container), containerFlags(r.container)...),
+		cmd:     r.cmd,
+	}
+	if r.proxied {
+		spec.labels = app.proxy().Labels(cfg.Service, cfg.Healthcheck)
+		spec.options = append(spec.options, healthFlags(cfg.Healthcheck)...)
+	}
+	return spec
+}
+
+// switchVersion registers steps that pull the image of version and replace the containers
+// running currentVersion with containers running version for every role on the host of tx,
+// using the configured deploy mode, or replace them in place if version is redeployed.
+// Images shipped without a registry are not pulled.
+func (app *App) switchVersion(ctx context.Context, tx txman.Transaction, version, currentVersion string) error {
+	cfg := config.Get()
+
+	var err error
+	if cfg.Registry.Mode != config.RegistryModeNone {
+		err = tx.Do(ctx, PullImage(app.imageName(version)), nil)
 		if err != nil {
 			return err
 		}
-		err = tx.Do(ctx, StopContainer(currentContainer), StartContainer(currentContainer))
+	}
+
+	proxied := false
+	for _, r := range rolesOn(appRoles(cfg), tx.Host()) {
+		spec := app.appContainerSpec(r, version)
+		currentContainer := app.containerName(r, currentVersion)
+		wait := waitForRole(r)(spec.name)
+		// the proxy only needs time to route to containers of proxied roles
+		var settle time.Duration
+		if r.proxied {
+			settle = cfg.Healthcheck.Interval
+			proxied = true
+		}
+
+		// a container of a previously deployed version with the same name may still exist
+		err = tx.Do(ctx, RemoveStoppedContainer(spec.name), nil)
 		if err != nil {
 			return err
 		}
-		err = tx.Do(ctx, RunContainer(image, newContainer, envs), StopContainer(newContainer))
+
+		switch {
+		case version == currentVersion:
+			err = swapRedeploy(ctx, tx, spec, wait)
+		case cfg.Deploy.Mode == config.DeployModeZeroDowntime:
+			err = swapZeroDowntime(ctx, tx, spec, currentContainer, wait, settle)
+		default:
+			err = swapStopStart(ctx, tx, spec, currentContainer, wait)
+		}
 		if err != nil {
 			return err
 		}
+	}
 
-		err = tx.Do(ctx, app.AppendVersion(newVersion), nil)
+	if !proxied {
+		return nil
+	}
+	return tx.Do(ctx, ReloadProxy(app.proxy()), ReloadProxy(app.proxy()))
+}
+
+// swapStopStart stops the current container, if any, and then runs the new one
+// and waits until it is ready. The app is unavailable on the host between the two steps.
+func swapStopStart(
+	ctx context.Context,
+	tx txman.Transaction,
+	spec containerSpec,
+	currentContainer string,
+	wait txman.Callback,
+) error {
+	if currentContainer != "" {
+		err := tx.Do(ctx, StopContainer(currentContainer), StartContainer(currentContainer))
 		if err != nil {
 			return err
 		}
+	}
+	err := tx.Do(ctx, spec.run(), StopContainer(spec.name))
+	if err != nil {
+		return err
+	}
+	return tx.Do(ctx, wait, nil)
+}
 
+// swapZeroDowntime runs the new container next to the current one, waits until it
+// is ready and the proxy has had settle time to route to it, and only then stops the current
+// container. If the new container is not ready, the registered rollback stops it and
+// the current one keeps serving traffic.
+func swapZeroDowntime(
+	ctx context.Context,
+	tx txman.Transaction,
+	spec containerSpec,
+	currentContainer string,
+	wait txman.Callback,
+	settle time.Duration,
+) error {
+	err := tx.Do(ctx, spec.run(), StopContainer(spec.name))
+	if err != nil {
+		return err
+	}
+	err = tx.Do(ctx, wait, nil)
+	if err != nil {
+		return err
+	}
+	// give the proxy one health check interval to pick up the new container
+	err = tx.Do(ctx, Pause(settle), nil)
+	if err != nil {
+		return err
+	}
+	if currentContainer == "" {
 		return nil
-	})
+	}
+	return tx.Do(ctx, StopContainer(currentContainer), StartContainer(currentContainer))
+}
 
+// swapRedeploy replaces the container of a redeployed version with a new one of the same
+// name. The current container is stopped and kept as a backup, which is restored if the new
+// container fails, until removeRedeployBackups removes it once the deploy is done. Both
+// containers cannot run at the same time, so redeploys are never zero-downtime.
+func swapRedeploy(ctx context.Context, tx txman.Transaction, spec containerSpec, wait txman.Callback) error {
+	backup := redeployBackupName(spec.name)
+	// a backup may be left over from an interrupted redeploy
+	err := tx.Run(ctx, command.RemoveContainer(backup), "")
 	if err != nil {
-		logging.Info("initiating rollback...")
-		rollbackCtx, rollbackCancel := context.WithTimeout(context.Background(), 30*time.Second)
-		defer rollbackCancel()
-		if err := rollback(rollbackCtx); err != nil {
-			return err
-		}
+		return err
 	}
+	err = tx.Run(ctx, command.BackupContainer(spec.name, backup), command.RestoreContainer(spec.name, backup))
+	if err != nil {
+		return err
+	}
+	err = tx.Do(ctx, spec.run(), nil)
+	if err != nil {
+		return err
+	}
+	return tx.Do(ctx, wait, nil)
+}
 
-	return nil
+// redeployBackupName returns the name container is kept under while it is redeployed.
+func redeployBackupName(container string) string {
+	return container + "-previous"
 }
 
+// removeRedeployBackups removes the containers that were replaced by redeploying version.
+// Hosts that failed restored their backups already, so nothing is removed there.
+// Failing to remove them is logged but not returned.
+func (app *App) removeRedeployBackups(ctx context.Context, version string) {
+	roles := appRoles(config.Get())
+	err := app.txmanager.Execute(ctx, func(ctx context.Context, client sshexec.Service) error {
+		for _, r := range rolesOn(roles, client.Host()) {
+			err := client.Run(ctx, command.RemoveContainer(redeployBackupName(app.containerName(r, version))))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		logging.Warnf("failed to remove containers replaced by the redeploy: %s", err)
+	}
+}
+
+// Rollback switches every host to a previously deployed version while holding the deploy lock.
 func (app *App) Rollback(ctx context.Context, version string) error {
-	err := app.LoadHistory(ctx)
+	return app.withLock(ctx, fmt.Sprintf("rollback to %s", version), func() error {
+		return app.rollback(ctx, version)
+	})
+}
+
+func (app *App) rollback(ctx context.Context, version string) error {
+	err := app.loadConsistentHistory(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to read history at %s: %w", app.historyFilePath, err)
 	}
+	if canary, ok := app.activeCanary(); ok {
+		return &CanaryActiveError{Canary: canary}
+	}
 
-	found := slices.IndexFunc(app.history, func(h History) bool { return h.Version == version })
+	found := slices.IndexFunc(app.history, func(h History) bool { return h.Version == version && app.deployedOnAnyHost(h) })
 	if found < 0 {
 		return fmt.Errorf("version %s does not exist", version)
 	}
-	// set timestamp for rolled version to current time
-	app.history[found].Timestamp = time.Now()
-	history, err := json.Marshal(app.history)
-	if err != nil {
+	currentVersion := app.LatestVersion()
+	// a rollback that succeeded only partially may be run again for the remaining hosts
+	if !slices.ContainsFunc(app.txmanager.Hosts(), func(host string) bool { return app.currentVersionOn(host) != version }) {
+		return fmt.Errorf("version %s is already deployed", version)
+	}
+	cfg := config.Get()
+	// nothing runs on hosts in dry-run mode, so there is no way to tell if containers exist
+	if !cfg.DryRun {
+		if err := app.checkContainers(ctx, version); err != nil {
+			return err
+		}
+	}
+	hc := hookContext{
+		service:         cfg.Service,
+		version:         version,
+		previousVersion: currentVersion,
+		hosts:           app.txmanager.Hosts(),
+		performer:       app.performer(ctx),
+	}
+	if err := app.runLocalHooks(ctx, hookPreRollback, cfg.Hooks.PreRollback, hc); err != nil {
 		return err
 	}
 
-	cfg := config.Get()
-	currentVersion := app.LatestVersion()
-	service := cfg.Service
-	currentContainer := fmt.Sprintf("%s-%s", service, currentVersion)
-	newContainer := fmt.Sprintf("%s-%s", service, version)
+	// set timestamp for rolled version to current time, without touching the host histories
+	deployedAt := app.history[found].Timestamp
+	now := time.Now()
+	app.history = slices.Clone(app.history)
+	app.history[found].Timestamp = now
+	app.history[found].Status = HistoryStatusSuccess
+	moveHistory := app.moveHistory(version, deployedAt, now, HistoryStatusSuccess)
 
+	// the container is recreated so that it runs with the current container options
 	rollback, err := app.txmanager.BeginTransaction(ctx, func(ctx context.Context, tx txman.Transaction) error {
-		err := tx.Do(ctx, StopContainer(currentContainer), StartContainer(currentContainer))
+		// the env file of the version is reused so that it runs with the env it was deployed with
+		err := app.pushEnv(ctx, tx, version, false)
 		if err != nil {
 			return err
 		}
-		err = tx.Do(ctx, StartContainer(newContainer), StopContainer(newContainer))
+		err = app.remoteHooks(ctx, tx, hookPreRollback, cfg.Hooks.PreRollback, hc)
 		if err != nil {
 			return err
 		}
-		err = tx.Do(ctx, WriteToRemoteFile(app.historyFilePath, history), nil)
+		// hosts that already run version, e.g. after a partial rollback, only get their history moved
+		if current := app.currentVersionOn(tx.Host()); current != version {
+			err = app.switchVersion(ctx, tx, version, current)
+			if err != nil {
+				return err
+			}
+		}
+		err = tx.Do(ctx, moveHistory, nil)
 		if err != nil {
 			return err
 		}
 
 		return nil
-	})
+	}, rollingOptions(cfg.Deploy.Rolling)...)
+
+	var partialErr *txman.PartialError
+	if errors.As(err, &partialErr) {
+		err = fmt.Errorf("rollback to %s succeeded only partially: %w", version, err)
+		if historyErr := app.recordPartialRollback(ctx, version, deployedAt, now, partialErr); historyErr != nil {
+			return errors.Join(err, historyErr)
+		}
+		return err
+	}
 
 	if err != nil {
 		logging.Info("initiating rollback...")
 		rollbackCtx, rollbackCancel := context.WithTimeout(context.Background(), 30*time.Second)
 		defer rollbackCancel()
-		if err := rollback(rollbackCtx); err != nil {
-			return err
+		if rollbackErr := rollback(rollbackCtx); rollbackErr != nil {
+			return errors.Join(err, rollbackErr)
 		}
+		return fmt.Errorf("rollback to %s failed and was reverted: %w", version, err)
+	}
+
+	if err := app.runPostHooks(ctx, hookPostRollback, cfg.Hooks.PostRollback, hc); err != nil {
+		return fmt.Errorf("rolled back to %s but %w", version, err)
 	}
 
 	return nil
 }
 
-func (app *App) History(ctx context.Context, sortDir string) ([]History, error) {
+// checkContainers returns an error naming the hosts where the container of version
+// does not exist for every role running on the host, e.g. because it was pruned.
+func (app *App) checkContainers(ctx context.Context, version string) error {
+	roles := appRoles(config.Get())
+	var mu sync.Mutex
+	var missing []string
+	err := app.txmanager.Execute(ctx, func(ctx context.Context, client sshexec.Service) error {
+		for _, r := range rolesOn(roles, client.Host()) {
+			var out bytes.Buffer
+			err := client.Run(ctx, command.ContainerID(app.containerName(r, version)), sshexec.WithStdout(&out))
+			if err != nil {
+				return err
+			}
+			if strings.TrimSpace(out.String()) == "" {
+				mu.Lock()
+				missing = append(missing, client.Host())
+				mu.Unlock()
+				return nil
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(missing) > 0 {
+		slices.Sort(missing)
+		return fmt.Errorf("container of version %s does not exist on %s", version, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// DeployedVersions returns the versions that were deployed successfully, most recent first.
+func (app *App) DeployedVersions(ctx context.Context) ([]string, error) {
 	if err := app.LoadHistory(ctx); err != nil {
 		return nil, err
 	}
+	return deployedVersions(app.history), nil
+}
+
+// RollbackTarget returns the current version and the version that was deployed steps
+// deploys before it.
+func (app *App) RollbackTarget(ctx context.Context, steps int) (string, string, error) {
+	if steps < 1 {
+		return "", "", errors.New("steps must be at least 1")
+	}
+	versions, err := app.DeployedVersions(ctx)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read history at %s: %w", app.historyFilePath, err)
+	}
+	if len(versions) == 0 {
+		return "", "", errors.New("no version is deployed yet")
+	}
+	if steps >= len(versions) {
+		return "", "", fmt.Errorf("cannot go back %d versions, only %d previous versions are in history", steps, len(versions)-1)
+	}
+	return versions[0], versions[steps], nil
+}
+
+func (app *App) History(ctx context.Context, sortDir string, filter HistoryFilter) ([]History, error) {
+	if err := app.LoadHistory(ctx); err != nil {
+		return nil, err
+	}
+
+	history := make([]History, 0, len(app.history))
+	for _, entry := range app.history {
+		if filter.match(entry) {
+			history = append(history, entry)
+		}
+	}
 
 	if sortDir == "asc" {
-		sort.Sort(ByDateAsc(app.history))
+		sort.Sort(ByDateAsc(history))
 	} else {
-		sort.Sort(ByDateDesc(app.history))
+		sort.Sort(ByDateDesc(history))
 	}
 
-	return app.history, nil
+	return history, nil
 }
 
 func (app *App) ShowServiceInfo(ctx context.Context) (map[string]string, error) {
 	cfg := config.Get()
-	return app.showInfo(ctx, cfg.Service)
+	if cfg.Role == "" {
+		return app.showInfo(ctx, cfg.Service)
+	}
+	roleApp, err := app.onHosts(cfg.Roles[cfg.Role].Hosts)
+	if err != nil {
+		return nil, err
+	}
+	if roleApp == nil {
+		return nil, fmt.Errorf("role %s does not run on any of the target hosts", cfg.Role)
+	}
+	return roleApp.showInfo(ctx, fmt.Sprintf("%s-%s-", cfg.Service, cfg.Role))
 }
 
 func (app *App) ShowProxyInfo(ctx context.Context) (map[string]string, error) {
@@ -264,64 +811,81 @@ func (app *App) ShowProxyInfo(ctx context.Context) (map[string]string, error) {
 }
 
 func (app *App) ServiceLogs(ctx context.Context, follow bool, lines int, since string) error {
-	cfg := config.Get()
 	if err := app.LoadHistory(ctx); err != nil {
 		return err
 	}
 
-	container := fmt.Sprintf("%s-%s", cfg.Service, app.LatestVersion())
-	return app.logs(ctx, container, follow, lines, since)
+	return app.forEachRole(ctx, true, func(ctx context.Context, r role, roleApp *App) error {
+		containerFor := app.serviceContainer(r)
+		return roleApp.logs(ctx, func(host string) string {
+			return command.ContainerLogs(containerFor(host), follow, lines, since)
+		})
+	})
 }
 
 func (app *App) ProxyLogs(ctx context.Context, follow bool, lines int, since string) error {
-	container := config.Get().Proxy.Container
-	return app.logs(ctx, container, follow, lines, since)
+	logsCmd := app.proxy().Logs(follow, lines, since)
+	return app.logs(ctx, func(string) string { return logsCmd })
 }
 
 func (app *App) StopService(ctx context.Context) error {
-	cfg := config.Get()
 	if err := app.LoadHistory(ctx); err != nil {
 		return err
 	}
-	container := fmt.Sprintf("%s-%s", cfg.Service, app.LatestVersion())
-	return app.stopContainer(ctx, container)
+	return app.forEachRole(ctx, false, func(ctx context.Context, r role, roleApp *App) error {
+		return roleApp.stopContainer(ctx, app.serviceContainer(r))
+	})
 }
 
 func (app *App) StopProxy(ctx context.Context) error {
 	container := config.Get().Proxy.Container
-	return app.stopContainer(ctx, container)
+	return app.stopContainer(ctx, fixedContainer(container))
 }
 
 func (app *App) StartService(ctx context.Context) error {
-	cfg := config.Get()
 	if err := app.LoadHistory(ctx); err != nil {
 		return err
 	}
-	container := fmt.Sprintf("%s-%s", cfg.Service, app.LatestVersion())
-	return app.startContainer(ctx, container)
+	return app.forEachRole(ctx, false, func(ctx context.Context, r role, roleApp *App) error {
+		return roleApp.startContainer(ctx, app.serviceContainer(r), waitForRole(r))
+	})
 }
 
 func (app *App) StartProxy(ctx context.Context) error {
 	container := config.Get().Proxy.Container
-	return app.startContainer(ctx, container)
+	return app.startContainer(ctx, fixedContainer(container), app.waitForProxy)
 }
 
 func (app *App) RestartService(ctx context.Context) error {
-	if err := app.StopService(ctx); err != nil {
+	if err := app.LoadHistory(ctx); err != nil {
 		return err
 	}
-	return app.StartService(ctx)
+	return app.forEachRole(ctx, false, func(ctx context.Context, r role, roleApp *App) error {
+		return roleApp.restartContainer(ctx, app.serviceContainer(r), waitForRole(r))
+	})
+}
+
+func (app *App) RestartProxy(ctx context.Context) error {
+	container := config.Get().Proxy.Container
+	return app.restartContainer(ctx, fixedContainer(container), app.waitForProxy)
 }
 
 func (app *App) RegistryLogin(ctx context.Context) error {
 	cfg := config.Get()
+	if cfg.Registry.Mode == config.RegistryModeNone {
+		return errors.New("there is no registry to log in to with registry.mode none")
+	}
 
 	registry := cfg.Registry.Server
 	username := cfg.Registry.Username
-	password := cfg.Registry.Password
+	password, err := app.secrets.Resolve(ctx, cfg.Registry.Password)
+	if err != nil {
+		return err
+	}
 
 	return app.txmanager.Execute(ctx, func(ctx context.Context, client sshexec.Service) error {
-		err := client.Run(ctx, command.RegistryLogin(registry, username, password))
+		// the password is passed on stdin to keep it out of the remote command line
+		err := client.Run(ctx, command.RegistryLogin(registry, username), sshexec.WithStdin(strings.NewReader(password)))
 		if err != nil {
 			return fmt.Errorf("failed to login to registry: %s", err)
 		}
@@ -330,6 +894,9 @@ func (app *App) RegistryLogin(ctx context.Context) error {
 }
 
 func (app *App) RegistryLogout(ctx context.Context) error {
+	if config.Get().Registry.Mode == config.RegistryModeNone {
+		return errors.New("there is no registry to log out of with registry.mode none")
+	}
 	err := app.txmanager.Execute(ctx, func(ctx context.Context, client sshexec.Service) error {
 		err := client.Run(ctx, command.RegistryLogout())
 		if err != nil {
@@ -361,35 +928,30 @@ func (app *App) ExecService(ctx context.Context, execCmd string, interactive boo
 	if err := app.LoadHistory(ctx); err != nil {
 		return err
 	}
-	cfg := config.Get()
-	container := fmt.Sprintf("%s-%s", cfg.Service, app.LatestVersion())
-	return app.exec(ctx, container, execCmd, interactive)
+	return app.forEachRole(ctx, false, func(ctx context.Context, r role, roleApp *App) error {
+		return roleApp.exec(ctx, app.serviceContainer(r), execCmd, interactive)
+	})
 }
 
 func (app *App) ExecProxy(ctx context.Context, execCmd string, interactive bool) error {
 	if err := app.LoadHistory(ctx); err != nil {
 		return err
 	}
-	return app.exec(ctx, config.Get().Proxy.Container, execCmd, interactive)
+	return app.exec(ctx, fixedContainer(config.Get().Proxy.Container), execCmd, interactive)
 }
 
-func (app *App) exec(ctx context.Context, container string, execCmd string, interactive bool) error {
+func (app *App) exec(ctx context.Context, containerFor containerFunc, execCmd string, interactive bool) error {
 	return app.txmanager.Execute(ctx, func(ctx context.Context, client sshexec.Service) error {
 		sessionOption := []sshexec.SessionOption{}
 		if interactive {
 			sessionOption = append(sessionOption, sshexec.WithPty())
 		}
-		return client.Run(ctx, command.Exec(container, execCmd, interactive), sessionOption...)
+		return client.Run(ctx, command.Exec(containerFor(client.Host()), execCmd, interactive), sessionOption...)
 	})
 }
 
-func (app *App) logs(
-	ctx context.Context,
-	container string,
-	follow bool,
-	lines int,
-	since string,
-) error {
+// logs streams the output of the logs command returned by logsFor for every host.
+func (app *App) logs(ctx context.Context, logsFor func(host string) string) error {
 	err := app.txmanager.Execute(ctx, func(ctx context.Context, client sshexec.Service) error {
 		var lineHandler stream.LineHandler = func(line []byte) {
 			logging.InfoHost(client.Host(), string(line))
@@ -401,7 +963,7 @@ func (app *App) logs(
 		sw := stream.New(lineHandler, streamErrHandler)
 		defer sw.Close()
 
-		err := client.Run(ctx, command.ContainerLogs(container, follow, lines, since), sshexec.WithStdout(sw))
+		err := client.Run(ctx, logsFor(client.Host()), sshexec.WithStdout(sw))
 		if err != nil {
 			return err
 		}
@@ -415,27 +977,66 @@ func (app *App) logs(
 	return nil
 }
 
-func (app *App) startContainer(ctx context.Context, container string) error {
+// waitFunc returns a callback that waits until container is ready.
+type waitFunc func(container string) txman.Callback
+
+// waitForRole returns the waitFunc for containers of role r. Only containers of proxied
+// roles have the configured health check, the others are ready once they run.
+func waitForRole(r role) waitFunc {
+	if !r.proxied {
+		return WaitForRunning
+	}
+	return func(container string) txman.Callback {
+		return WaitForHealthy(container, config.Get().Healthcheck)
+	}
+}
+
+func (app *App) waitForProxy(string) txman.Callback {
+	return WaitForProxy(app.proxy())
+}
+
+// startContainer starts container on every host and waits until it is ready.
+func (app *App) startContainer(ctx context.Context, containerFor containerFunc, wait waitFunc) error {
 	return app.txmanager.Execute(ctx, func(ctx context.Context, client sshexec.Service) error {
+		container := containerFor(client.Host())
 		err := client.Run(ctx, command.StartContainer(container))
 		if err != nil {
 			return fmt.Errorf("failed to start container on %s: %w", client.Host(), err)
 		}
-		return nil
+		return wait(container)(ctx, client)
 	})
 }
 
-func (app *App) stopContainer(ctx context.Context, container string) error {
+// restartContainer stops and starts container host by host, in batches if rolling updates are configured,
+// and waits until it is ready before moving on.
+func (app *App) restartContainer(ctx context.Context, containerFor containerFunc, wait waitFunc) error {
+	cfg := config.Get()
 	return app.txmanager.Execute(ctx, func(ctx context.Context, client sshexec.Service) error {
+		container := containerFor(client.Host())
 		err := client.Run(ctx, command.StopContainer(container))
 		if err != nil {
 			return fmt.Errorf("failed to stop container on %s: %w", client.Host(), err)
 		}
+		err = client.Run(ctx, command.StartContainer(container))
+		if err != nil {
+			return fmt.Errorf("failed to start container on %s: %w", client.Host(), err)
+		}
+		return wait(container)(ctx, client)
+	}, rollingOptions(cfg.Deploy.Rolling)...)
+}
+
+func (app *App) stopContainer(ctx context.Context, containerFor containerFunc) error {
+	return app.txmanager.Execute(ctx, func(ctx context.Context, client sshexec.Service) error {
+		err := client.Run(ctx, command.StopContainer(containerFor(client.Host())))
+		if err != nil {
+			return fmt.Errorf("failed to stop container on %s: %w", client.Host(), err)
+		}
 		return nil
 	})
 }
 
 func (app *App) showInfo(ctx context.Context, container string) (map[string]string, error) {
+	var mu sync.Mutex
 	output := make(map[string]string)
 	err := app.txmanager.Execute(ctx, func(ctx context.Context, client sshexec.Service) error {
 		var stdout bytes.Buffer
@@ -443,7 +1044,9 @@ func (app *App) showInfo(ctx context.Context, container string) (map[string]stri
 		if err != nil {
 			return err
 		}
+		mu.Lock()
 		output[client.Host()] = stdout.String()
+		mu.Unlock()
 		return nil
 	})
 
@@ -0,0 +1,23 @@
+package app
+
+import (
+	"os"
+	"testing"
+
+	"github.com/lex-unix/faino/internal/config"
+	"github.com/spf13/pflag"
+)
+
+// loadTestConfig loads data as faino.yaml from a temporary working directory into the global config.
+func loadTestConfig(t *testing.T, data string) {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	err := os.WriteFile("faino.yaml", []byte(data), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = config.Load(pflag.NewFlagSet("test", pflag.ContinueOnError))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,113 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/lex-unix/faino/internal/command"
+	"github.com/lex-unix/faino/internal/config"
+	"github.com/lex-unix/faino/internal/exec/localexec"
+	"github.com/lex-unix/faino/internal/logging"
+)
+
+// remoteBuilderNode is the name of the builder node on the remote build host
+const remoteBuilderNode = "faino-remote"
+
+// CreateBuilder creates the builder that images are built with. The local node builds for
+// the configured platforms, except for the native platform of the remote build host, if
+// any, which is built by a node on that host.
+func (app *App) CreateBuilder(ctx context.Context) error {
+	cfg := config.Get()
+	if app.builderExists(ctx) {
+		return fmt.Errorf("builder %s already exists, run `faino build remove` to remove it first", cfg.Build.Builder)
+	}
+
+	platforms := strings.Split(cfg.Build.Platform, ",")
+
+	var endpoint, remotePlatform string
+	if cfg.Build.Remote != "" {
+		endpoint = builderEndpoint(cfg.Build.Remote, cfg.SSH.User)
+		var out bytes.Buffer
+		err := app.lexec.Run(ctx, command.EndpointPlatform(endpoint), localexec.WithStdout(&out))
+		if err != nil {
+			return fmt.Errorf("failed to read docker platform of remote builder %s: %w", endpoint, err)
+		}
+		remotePlatform = strings.TrimSpace(out.String())
+		localPlatforms := slices.DeleteFunc(slices.Clone(platforms), func(p string) bool { return p == remotePlatform })
+		// the local node is kept for every platform if the remote one covers them all
+		if len(localPlatforms) > 0 {
+			platforms = localPlatforms
+		}
+	}
+
+	logging.Infof("creating docker builder %s", cfg.Build.Builder)
+	err := app.lexec.Run(ctx, command.CreateBuilder(cfg.Build.Builder, cfg.Build.Driver, strings.Join(platforms, ",")))
+	if err != nil {
+		return fmt.Errorf("failed to create builder %s: %w", cfg.Build.Builder, err)
+	}
+	if endpoint == "" {
+		return nil
+	}
+
+	logging.Infof("adding remote builder %s for %s", endpoint, remotePlatform)
+	err = app.lexec.Run(ctx, command.AppendBuilderNode(cfg.Build.Builder, remoteBuilderNode, cfg.Build.Driver, remotePlatform, endpoint))
+	if err != nil {
+		return fmt.Errorf("failed to add remote builder %s: %w", endpoint, err)
+	}
+	return nil
+}
+
+// RemoveBuilder removes the builder with all of its nodes.
+func (app *App) RemoveBuilder(ctx context.Context) error {
+	cfg := config.Get()
+	if !app.builderExists(ctx) {
+		return fmt.Errorf("builder %s does not exist", cfg.Build.Builder)
+	}
+	return app.lexec.Run(ctx, command.RemoveBuilder(cfg.Build.Builder))
+}
+
+// BuilderDetails returns the description of the builder and its nodes as reported by buildx.
+func (app *App) BuilderDetails(ctx context.Context) (string, error) {
+	cfg := config.Get()
+	var out bytes.Buffer
+	err := app.lexec.Run(ctx, command.InspectBuilder(cfg.Build.Builder), localexec.WithStdout(&out))
+	if err != nil {
+		return "", fmt.Errorf("builder %s does not exist, run `faino build create` to create it", cfg.Build.Builder)
+	}
+	return out.String(), nil
+}
+
+// checkBuilder fails unless the builder exists. It warns if the builder
+// lacks the node of the configured remote build host.
+func (app *App) checkBuilder(ctx context.Context) error {
+	cfg := config.Get()
+	details, err := app.BuilderDetails(ctx)
+	if err != nil {
+		return err
+	}
+	if cfg.Build.Remote != "" && !strings.Contains(details, remoteBuilderNode) {
+		logging.Warnf("builder %s has no remote node, run `faino build remove` and `faino build create` to add it", cfg.Build.Builder)
+	}
+	return nil
+}
+
+// builderExists reports whether the builder exists.
+func (app *App) builderExists(ctx context.Context) bool {
+	_, err := app.BuilderDetails(ctx)
+	return err == nil
+}
+
+// builderEndpoint returns the docker endpoint of the remote build host. Hosts without
+// a scheme are reached over ssh, as user unless the host names one.
+func builderEndpoint(remote, user string) string {
+	if strings.Contains(remote, "://") {
+		return remote
+	}
+	if !strings.Contains(remote, "@") {
+		remote = user + "@" + remote
+	}
+	return "ssh://" + remote
+}
@@ -0,0 +1,14 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuilderEndpoint(t *testing.T) {
+	assert.Equal(t, "ssh://root@build.example.com", builderEndpoint("build.example.com", "root"))
+	assert.Equal(t, "ssh://deploy@build.example.com", builderEndpoint("deploy@build.example.com", "root"))
+	assert.Equal(t, "ssh://deploy@build.example.com:2222", builderEndpoint("ssh://deploy@build.example.com:2222", "root"))
+	assert.Equal(t, "tcp://10.0.0.5:2376", builderEndpoint("tcp://10.0.0.5:2376", "root"))
+}
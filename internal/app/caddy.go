@@ -0,0 +1,123 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lex-unix/faino/internal/command"
+	"github.com/lex-unix/faino/internal/config"
+)
+
+// caddyDataDir is where Caddy stores certificates inside its container
+const caddyDataDir = "/data"
+
+// caddyProxy routes requests with Caddy using the caddy-docker-proxy plugin,
+// which builds the Caddyfile from app container labels.
+// Caddy issues certificates for the routed hosts on its own when SSL is enabled.
+type caddyProxy struct {
+	cfg     config.Proxy
+	network string
+}
+
+func (p *caddyProxy) Run(labels ...string) string {
+	ssl := p.cfg.SSL
+	options := []string{
+		"-p 80:80",
+		"-p 443:443",
+		fmt.Sprintf("--volume %s:%s", ssl.Volume, caddyDataDir),
+	}
+	if p.network != "" {
+		options = append(options, envFlag("CADDY_INGRESS_NETWORKS", p.network))
+	}
+
+	// global options are read from the labels of the proxy container
+	var globals []string
+	if ssl.Enabled && ssl.Email != "" {
+		globals = append(globals, "--label caddy.email="+ssl.Email)
+	}
+	if ssl.Enabled && ssl.CAServer != "" {
+		globals = append(globals, "--label caddy.acme_ca="+ssl.CAServer)
+	}
+	if ssl.Enabled && ssl.CACertificates != "" {
+		options = append(options, fmt.Sprintf("--volume %s:%s:ro", ssl.CACertificates, caCertificatesPath))
+		globals = append(globals, "--label caddy.acme_ca_root="+caCertificatesPath)
+	}
+	options = append(options, globals...)
+	options = append(options, labelFlags(p.cfg.Labels, labels)...)
+
+	args := "docker-proxy"
+	if len(p.cfg.Args) > 0 {
+		args += " " + formatArgs(p.cfg.Args)
+	}
+
+	return command.RunProxy(p.cfg.Img, p.cfg.Container, options, args)
+}
+
+// Reload returns an empty string, caddy-docker-proxy regenerates its config
+// when app containers change.
+func (p *caddyProxy) Reload() string {
+	return ""
+}
+
+// Labels returns labels that add the app to the Caddyfile. Containers of the same
+// service share a site, so old and new containers are load balanced while they are swapped.
+func (p *caddyProxy) Labels(service string, hc config.Healthcheck) []string {
+	r := p.cfg.Routing
+	labels := []string{"caddy=" + p.siteAddress()}
+
+	upstreams := "{{upstreams}}"
+	if port := upstreamPort(r, hc); port != 0 {
+		upstreams = fmt.Sprintf("{{upstreams %d}}", port)
+	}
+
+	reverseProxy := "caddy.reverse_proxy"
+	if len(r.Paths) > 0 {
+		matcher := "@" + routeName(service)
+		paths := make([]string, len(r.Paths))
+		for i, path := range r.Paths {
+			paths[i] = strings.TrimSuffix(path, "/") + "*"
+		}
+		labels = append(labels,
+			fmt.Sprintf("caddy.%s.path=%s", matcher, strings.Join(paths, " ")),
+			fmt.Sprintf("%s=%s %s", reverseProxy, matcher, upstreams),
+		)
+	} else {
+		labels = append(labels, fmt.Sprintf("%s=%s", reverseProxy, upstreams))
+	}
+
+	if hc.Port != 0 {
+		labels = append(labels,
+			fmt.Sprintf("%s.health_uri=%s", reverseProxy, hc.Path),
+			fmt.Sprintf("%s.health_port=%d", reverseProxy, hc.Port),
+			fmt.Sprintf("%s.health_interval=%s", reverseProxy, hc.Interval),
+			fmt.Sprintf("%s.health_timeout=%s", reverseProxy, hc.Timeout),
+		)
+	}
+	return labels
+}
+
+// siteAddress returns the Caddyfile site address of the app. Hosts are served
+// over plain HTTP unless SSL is enabled.
+func (p *caddyProxy) siteAddress() string {
+	r := p.cfg.Routing
+	if len(r.Hosts) == 0 {
+		return ":80"
+	}
+	hosts := make([]string, len(r.Hosts))
+	for i, host := range r.Hosts {
+		if p.cfg.SSL.Enabled {
+			hosts[i] = host
+		} else {
+			hosts[i] = "http://" + host
+		}
+	}
+	return strings.Join(hosts, " ")
+}
+
+func (p *caddyProxy) Health() string {
+	return command.Exec(p.cfg.Container, "wget --quiet --output-document /dev/null http://localhost:2019/config/", false)
+}
+
+func (p *caddyProxy) Logs(follow bool, lines int, since string) string {
+	return command.ContainerLogs(p.cfg.Container, follow, lines, since)
+}
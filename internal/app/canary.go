@@ -0,0 +1,384 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/lex-unix/faino/internal/config"
+	"github.com/lex-unix/faino/internal/exec/sshexec"
+	"github.com/lex-unix/faino/internal/logging"
+	"github.com/lex-unix/faino/internal/txman"
+)
+
+// CanaryActiveError is returned when an operation is not allowed while a canary is deployed.
+type CanaryActiveError struct {
+	Canary History
+}
+
+func (e *CanaryActiveError) Error() string {
+	return fmt.Sprintf(
+		"canary version %s is deployed to %v, promote it with `faino deploy promote` or abort it with `faino deploy abort`",
+		e.Canary.Version,
+		e.Canary.Hosts,
+	)
+}
+
+// containerFunc resolves the container to operate on for a host.
+type containerFunc func(host string) string
+
+// fixedContainer returns a containerFunc that resolves to container on every host.
+func fixedContainer(container string) containerFunc {
+	return func(string) string { return container }
+}
+
+// serviceContainer returns a containerFunc that resolves to the app container of role r running on a host.
+// Canary hosts resolve to the canary container, all other hosts to the latest deployed version.
+// History must be loaded for canary hosts to be resolved.
+func (app *App) serviceContainer(r role) containerFunc {
+	canary, ok := app.activeCanary()
+	return func(host string) string {
+		if ok && slices.Contains(canary.Hosts, host) {
+			return app.containerName(r, canary.Version)
+		}
+		return app.containerName(r, app.currentVersionOn(host))
+	}
+}
+
+// activeCanary returns the canary entry that was neither promoted nor aborted, if any.
+// A promotion that was rolled back on some hosts ends the canary as well.
+func (app *App) activeCanary() (History, bool) {
+	app.sortHistory()
+	var rolledBack []string
+	for _, entry := range app.history {
+		switch entry.Status {
+		case HistoryStatusSuccess:
+			return History{}, false
+		case HistoryStatusRolledBack:
+			rolledBack = append(rolledBack, entry.Version)
+		case HistoryStatusCanary:
+			return entry, !slices.Contains(rolledBack, entry.Version)
+		}
+	}
+	return History{}, false
+}
+
+// deployCanary deploys the version described by entry to the canary hosts only and
+// records it in history on every host as a canary.
+func (app *App) deployCanary(
+	ctx context.Context,
+	hosts []string,
+	entry History,
+	hc hookContext,
+	startedAt time.Time,
+) error {
+	hosts = slices.Sorted(slices.Values(hosts))
+	hosts = slices.Compact(hosts)
+	if len(hosts) == len(app.txmanager.Hosts()) {
+		return errors.New("canary hosts must be a subset of the target hosts, use a regular deploy instead")
+	}
+	canaryTx, err := app.txmanager.Subset(hosts...)
+	if err != nil {
+		return err
+	}
+	logging.Infof("deploying canary version %s to %v", entry.Version, hosts)
+
+	cfg := config.Get()
+	rollback, err := canaryTx.BeginTransaction(ctx, func(ctx context.Context, tx txman.Transaction) error {
+		err := app.pushEnv(ctx, tx, entry.Version, true)
+		if err != nil {
+			return err
+		}
+		err = app.remoteHooks(ctx, tx, hookPreDeploy, cfg.Hooks.PreDeploy, hc)
+		if err != nil {
+			return err
+		}
+		return app.switchVersion(ctx, tx, entry.Version, hc.previousVersion)
+	})
+	if err == nil {
+		entry.Status = HistoryStatusCanary
+		entry.Hosts = hosts
+		entry.Timestamp = time.Now()
+		entry.Duration = entry.Timestamp.Sub(startedAt)
+		err = app.replaceHistory(ctx, appendHistory(entry))
+	}
+	if err != nil {
+		logging.Info("initiating rollback...")
+		rollbackCtx, rollbackCancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer rollbackCancel()
+		if rollbackErr := rollback(rollbackCtx); rollbackErr != nil {
+			entry.Status = HistoryStatusFailed
+			app.recordOutcome(rollbackCtx, entry, startedAt)
+			return errors.Join(err, rollbackErr)
+		}
+		entry.Status = HistoryStatusRolledBack
+		app.recordOutcome(rollbackCtx, entry, startedAt)
+		return fmt.Errorf("canary deploy failed and was rolled back: %w", err)
+	}
+
+	return nil
+}
+
+// watchCanary waits for d and promotes the active canary if it still passes the
+// health check, or aborts it otherwise. Without a health check configured
+// the canary is promoted once d elapses.
+func (app *App) watchCanary(ctx context.Context, d time.Duration) error {
+	cfg := config.Get()
+
+	canary, ok := app.activeCanary()
+	if !ok {
+		return errors.New("there is no canary to promote")
+	}
+	logging.Infof("promoting canary version %s in %s", canary.Version, d)
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+	}
+
+	if cfg.Healthcheck.Enabled() {
+		canaryTx, err := app.txmanager.Subset(canary.Hosts...)
+		if err != nil {
+			return err
+		}
+		err = canaryTx.Execute(ctx, app.waitForVersion(canary.Version))
+		if err != nil {
+			logging.Warnf("canary version %s is unhealthy: %s", canary.Version, err)
+			if abortErr := app.abortCanary(ctx); abortErr != nil {
+				return errors.Join(err, abortErr)
+			}
+			return fmt.Errorf("canary version %s was aborted: %w", canary.Version, err)
+		}
+	}
+
+	return app.promoteCanary(ctx)
+}
+
+// waitForVersion returns a callback that waits until the containers of proxied roles
+// running version on the host pass the health check.
+func (app *App) waitForVersion(version string) txman.Callback {
+	cfg := config.Get()
+	return func(ctx context.Context, client sshexec.Service) error {
+		for _, r := range rolesOn(appRoles(cfg), client.Host()) {
+			if !r.proxied {
+				continue
+			}
+			err := WaitForHealthy(app.containerName(r, version), cfg.Healthcheck)(ctx, client)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// PromoteCanary deploys the active canary version to the remaining hosts while holding the deploy lock.
+func (app *App) PromoteCanary(ctx context.Context) error {
+	return app.withLock(ctx, "promote canary", func() error {
+		return app.promoteCanary(ctx)
+	})
+}
+
+func (app *App) promoteCanary(ctx context.Context) error {
+	cfg := config.Get()
+
+	err := app.loadConsistentHistory(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read history at %s: %w", app.historyFilePath, err)
+	}
+	canary, ok := app.activeCanary()
+	if !ok {
+		return errors.New("there is no canary to promote")
+	}
+
+	remaining := slices.DeleteFunc(app.txmanager.Hosts(), func(host string) bool {
+		return slices.Contains(canary.Hosts, host)
+	})
+	logging.Infof("promoting canary version %s to %v", canary.Version, remaining)
+	hc := hookContext{
+		service:         cfg.Service,
+		version:         canary.Version,
+		previousVersion: app.LatestVersion(),
+		hosts:           app.txmanager.Hosts(),
+		performer:       app.performer(ctx),
+	}
+
+	rollback := func(context.Context) error { return nil }
+	if len(remaining) > 0 {
+		remainingTx, err := app.txmanager.Subset(remaining...)
+		if err != nil {
+			return err
+		}
+		rollback, err = remainingTx.BeginTransaction(ctx, func(ctx context.Context, tx txman.Transaction) error {
+			err := app.pushEnv(ctx, tx, canary.Version, true)
+			if err != nil {
+				return err
+			}
+			return app.switchVersion(ctx, tx, canary.Version, app.currentVersionOn(tx.Host()))
+		}, rollingOptions(cfg.Deploy.Rolling)...)
+		var partialErr *txman.PartialError
+		if errors.As(err, &partialErr) {
+			// failed hosts were rolled back and keep running the previous version,
+			// so the promotion is recorded as rolled back on them
+			promoted := promotedEntry(canary)
+			historyErr := app.replaceHistory(ctx, func(history []History, host string) []History {
+				entry := promoted
+				if _, failed := partialErr.Errs[host]; failed {
+					entry.Status = HistoryStatusRolledBack
+				}
+				return append(history, entry)
+			})
+			err = fmt.Errorf("canary version %s was promoted only partially: %w", canary.Version, err)
+			if historyErr != nil {
+				return errors.Join(err, historyErr)
+			}
+			return err
+		}
+		if err == nil {
+			err = app.replaceHistory(ctx, appendHistory(promotedEntry(canary)))
+		}
+	} else {
+		err = app.replaceHistory(ctx, appendHistory(promotedEntry(canary)))
+	}
+	if err != nil {
+		logging.Info("initiating rollback...")
+		rollbackCtx, rollbackCancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer rollbackCancel()
+		if rollbackErr := rollback(rollbackCtx); rollbackErr != nil {
+			return errors.Join(err, rollbackErr)
+		}
+		return fmt.Errorf("canary promotion failed and was rolled back: %w", err)
+	}
+
+	if err := app.runPostHooks(ctx, hookPostDeploy, cfg.Hooks.PostDeploy, hc); err != nil {
+		return fmt.Errorf("canary version %s was promoted but %w", canary.Version, err)
+	}
+
+	return nil
+}
+
+// promotedEntry returns the history entry recording that canary was deployed to every host.
+func promotedEntry(canary History) History {
+	entry := canary
+	entry.Status = HistoryStatusSuccess
+	entry.Hosts = nil
+	entry.Timestamp = time.Now()
+	return entry
+}
+
+// AbortCanary reverts the canary hosts to the previous version while holding the deploy lock.
+func (app *App) AbortCanary(ctx context.Context) error {
+	return app.withLock(ctx, "abort canary", func() error {
+		return app.abortCanary(ctx)
+	})
+}
+
+func (app *App) abortCanary(ctx context.Context) error {
+	err := app.loadConsistentHistory(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read history at %s: %w", app.historyFilePath, err)
+	}
+	canary, ok := app.activeCanary()
+	if !ok {
+		return errors.New("there is no canary to abort")
+	}
+	canaryTx, err := app.txmanager.Subset(canary.Hosts...)
+	if err != nil {
+		return err
+	}
+	logging.Infof("aborting canary version %s on %v", canary.Version, canary.Hosts)
+
+	cfg := config.Get()
+	stableVersion := app.LatestVersion()
+
+	rollback, err := canaryTx.BeginTransaction(ctx, func(ctx context.Context, tx txman.Transaction) error {
+		for _, r := range rolesOn(appRoles(cfg), tx.Host()) {
+			stableContainer := app.containerName(r, stableVersion)
+			canaryContainer := app.containerName(r, canary.Version)
+			if stableContainer != "" {
+				err := tx.Do(ctx, StartContainer(stableContainer), StopContainer(stableContainer))
+				if err != nil {
+					return err
+				}
+				err = tx.Do(ctx, waitForRole(r)(stableContainer), nil)
+				if err != nil {
+					return err
+				}
+			}
+			err := tx.Do(ctx, StopContainer(canaryContainer), StartContainer(canaryContainer))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err == nil {
+		err = app.replaceHistory(ctx, func(history []History, _ string) []History {
+			for i := range history {
+				if history[i].Status == HistoryStatusCanary && history[i].Version == canary.Version {
+					history[i].Status = HistoryStatusRolledBack
+				}
+			}
+			return history
+		})
+	}
+	if err != nil {
+		logging.Info("initiating rollback...")
+		rollbackCtx, rollbackCancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer rollbackCancel()
+		if rollbackErr := rollback(rollbackCtx); rollbackErr != nil {
+			return errors.Join(err, rollbackErr)
+		}
+		return fmt.Errorf("canary abort failed and was reverted: %w", err)
+	}
+
+	return nil
+}
+
+// replaceHistory writes the history that update returns for every host, given a copy of
+// the current history of the host. If writing fails on any host, the history file is
+// restored on the hosts it was already written to. The in-memory history becomes what
+// update returns for an empty host.
+func (app *App) replaceHistory(ctx context.Context, update func(history []History, host string) []History) error {
+	updated := make(map[string][]History)
+	data := make(map[string][]byte)
+	original := make(map[string][]byte)
+	for _, host := range app.txmanager.Hosts() {
+		history := app.historyOn(host)
+		raw, err := encodeHistory(history)
+		if err != nil {
+			return fmt.Errorf("failed to marshal history: %w", err)
+		}
+		original[host] = raw
+		updated[host] = update(slices.Clone(history), host)
+		if data[host], err = encodeHistory(updated[host]); err != nil {
+			return fmt.Errorf("failed to marshal history: %w", err)
+		}
+	}
+
+	rollback, err := app.txmanager.BeginTransaction(ctx, func(ctx context.Context, tx txman.Transaction) error {
+		return tx.Do(ctx, writeHostFiles(app.historyFilePath, data), writeHostFiles(app.historyFilePath, original))
+	})
+	if err != nil {
+		rollbackCtx, rollbackCancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer rollbackCancel()
+		if rollbackErr := rollback(rollbackCtx); rollbackErr != nil {
+			return errors.Join(err, rollbackErr)
+		}
+		return err
+	}
+
+	app.history = update(slices.Clone(app.history), "")
+	app.historySorted = false
+	app.hostHistory = updated
+	return nil
+}
+
+// appendHistory returns a replaceHistory update that appends entry on every host.
+func appendHistory(entry History) func([]History, string) []History {
+	return func(history []History, _ string) []History {
+		return append(history, entry)
+	}
+}
@@ -0,0 +1,246 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/lex-unix/faino/internal/command"
+	"github.com/lex-unix/faino/internal/config"
+	"github.com/lex-unix/faino/internal/exec/sshexec"
+	"github.com/lex-unix/faino/internal/txman"
+)
+
+// envFilesDir is where env files of app containers are written on hosts
+const envFilesDir = "$HOME/.faino/env"
+
+// envFilePath returns the path of the env file of container on hosts.
+func envFilePath(container string) string {
+	return fmt.Sprintf("%s/%s.env", envFilesDir, container)
+}
+
+// encodeEnvFile returns env in the format of docker env files.
+// Values are taken literally, so they must not contain newlines.
+func encodeEnvFile(env map[string]string) []byte {
+	var buf bytes.Buffer
+	for _, k := range slices.Sorted(maps.Keys(env)) {
+		fmt.Fprintf(&buf, "%s=%s\n", k, env[k])
+	}
+	return buf.Bytes()
+}
+
+// decodeEnvFile parses a docker env file. Comments and blank lines are skipped.
+func decodeEnvFile(data []byte) map[string]string {
+	env := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		k, v, _ := strings.Cut(line, "=")
+		env[k] = v
+	}
+	return env
+}
+
+// WriteEnvFile returns a callback that writes env to the env file at path, readable by the
+// ssh user only. Unless overwrite is set, an existing file is left as is.
+func WriteEnvFile(path string, env map[string]string, overwrite bool) txman.Callback {
+	return func(ctx context.Context, client sshexec.Service) error {
+		if !overwrite && client.Run(ctx, command.FileExists(path)) == nil {
+			return nil
+		}
+		if err := client.Run(ctx, command.PrepareSecretFile(path)); err != nil {
+			return err
+		}
+		return client.WriteFile(path, encodeEnvFile(env))
+	}
+}
+
+// pushEnv registers steps that write the env files of the containers running version for
+// every role on the host of tx. Unless overwrite is set, existing env files are reused,
+// e.g. when rolling back to a version that was deployed before.
+func (app *App) pushEnv(ctx context.Context, tx txman.Transaction, version string, overwrite bool) error {
+	cfg := config.Get()
+	for _, r := range rolesOn(appRoles(cfg), tx.Host()) {
+		env, err := app.resolvedEnv(ctx, r)
+		if err != nil {
+			return err
+		}
+		path := envFilePath(app.containerName(r, version))
+		err = tx.Do(ctx, WriteEnvFile(path, env, overwrite), nil)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolvedEnv returns the env of the containers of role r with secret references resolved.
+func (app *App) resolvedEnv(ctx context.Context, r role) (map[string]string, error) {
+	env, err := app.secrets.ResolveMap(ctx, roleEnv(config.Get().Env, r))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range env {
+		if strings.Contains(v, "\n") {
+			return nil, fmt.Errorf("env %s must not contain newlines", k)
+		}
+	}
+	return env, nil
+}
+
+// resolveSecrets resolves every secret reference in build secrets and env of every role.
+// Resolved values are cached, so later lookups do not run secret managers again.
+func (app *App) resolveSecrets(ctx context.Context) error {
+	cfg := config.Get()
+	if _, err := app.secrets.ResolveMap(ctx, cfg.Secrets); err != nil {
+		return err
+	}
+	for _, r := range appRoles(cfg) {
+		if _, err := app.resolvedEnv(ctx, r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EnvFile is the env file of an app container on a host.
+type EnvFile struct {
+	Host      string
+	Container string
+	Env       map[string]string
+}
+
+// EnvDiff describes how the env file of an app container on a host differs from the config.
+type EnvDiff struct {
+	Host      string
+	Container string
+	// Added lists variables that are in the config but not in the env file.
+	Added []string
+	// Removed lists variables that are in the env file but not in the config.
+	Removed []string
+	// Changed lists variables whose values differ.
+	Changed []string
+}
+
+// Empty reports whether the env file matches the config.
+func (d EnvDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// PushEnv writes the env files of the latest version from the config to every host.
+// Containers pick up the new env once they are restarted.
+func (app *App) PushEnv(ctx context.Context) error {
+	version, err := app.envVersion(ctx)
+	if err != nil {
+		return err
+	}
+	return app.forEachRole(ctx, false, func(ctx context.Context, r role, roleApp *App) error {
+		env, err := app.resolvedEnv(ctx, r)
+		if err != nil {
+			return err
+		}
+		path := envFilePath(app.containerName(r, version))
+		return roleApp.txmanager.Execute(ctx, WriteEnvFile(path, env, true))
+	})
+}
+
+// ShowEnv reads the env files of the latest version from every host.
+func (app *App) ShowEnv(ctx context.Context) ([]EnvFile, error) {
+	version, err := app.envVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var mu sync.Mutex
+	var files []EnvFile
+	err = app.forEachRole(ctx, false, func(ctx context.Context, r role, roleApp *App) error {
+		container := app.containerName(r, version)
+		return roleApp.txmanager.Execute(ctx, func(ctx context.Context, client sshexec.Service) error {
+			path := envFilePath(container)
+			if err := client.Run(ctx, command.FileExists(path)); err != nil {
+				return fmt.Errorf("env file of %s does not exist on %s, run `faino env push` to write it", container, client.Host())
+			}
+			data, err := client.ReadFile(path)
+			if err != nil {
+				return fmt.Errorf("failed to read env file of %s on %s: %w", container, client.Host(), err)
+			}
+			mu.Lock()
+			files = append(files, EnvFile{Host: client.Host(), Container: container, Env: decodeEnvFile(data)})
+			mu.Unlock()
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(files, func(a, b EnvFile) int {
+		return strings.Compare(a.Host+"/"+a.Container, b.Host+"/"+b.Container)
+	})
+	return files, nil
+}
+
+// DiffEnv compares the env files of the latest version on every host with the config.
+func (app *App) DiffEnv(ctx context.Context) ([]EnvDiff, error) {
+	files, err := app.ShowEnv(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cfg := config.Get()
+	roles := appRoles(cfg)
+	version := app.LatestVersion()
+
+	diffs := make([]EnvDiff, 0, len(files))
+	for _, file := range files {
+		i := slices.IndexFunc(roles, func(r role) bool { return app.containerName(r, version) == file.Container })
+		env, err := app.resolvedEnv(ctx, roles[i])
+		if err != nil {
+			return nil, err
+		}
+		diff := diffEnv(env, file.Env)
+		diff.Host = file.Host
+		diff.Container = file.Container
+		diffs = append(diffs, diff)
+	}
+	return diffs, nil
+}
+
+// envVersion loads history and returns the latest version, whose env files env commands manage.
+func (app *App) envVersion(ctx context.Context) (string, error) {
+	if err := app.LoadHistory(ctx); err != nil {
+		return "", fmt.Errorf("failed to read history at %s: %w", app.historyFilePath, err)
+	}
+	version := app.LatestVersion()
+	if version == "" {
+		return "", fmt.Errorf("no version is deployed yet")
+	}
+	return version, nil
+}
+
+// diffEnv returns the variables that want adds to, removes from and changes in got.
+func diffEnv(want, got map[string]string) EnvDiff {
+	var diff EnvDiff
+	for _, k := range slices.Sorted(maps.Keys(want)) {
+		v, ok := got[k]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, k)
+		case v != want[k]:
+			diff.Changed = append(diff.Changed, k)
+		}
+	}
+	for _, k := range slices.Sorted(maps.Keys(got)) {
+		if _, ok := want[k]; !ok {
+			diff.Removed = append(diff.Removed, k)
+		}
+	}
+	return diff
+}
@@ -0,0 +1,31 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnvFile(t *testing.T) {
+	env := map[string]string{
+		"DATABASE_URL": "postgres://app:p=ss@db/app",
+		"EMPTY":        "",
+		"GREETING":     `say "hi" $USER`,
+	}
+
+	data := encodeEnvFile(env)
+	assert.Equal(t, "DATABASE_URL=postgres://app:p=ss@db/app\nEMPTY=\nGREETING=say \"hi\" $USER\n", string(data))
+	assert.Equal(t, env, decodeEnvFile(append([]byte("# comment\n\n"), data...)))
+}
+
+func TestDiffEnv(t *testing.T) {
+	want := map[string]string{"A": "1", "B": "2", "C": "3"}
+	got := map[string]string{"B": "2", "C": "4", "D": "5"}
+
+	diff := diffEnv(want, got)
+	assert.Equal(t, []string{"A"}, diff.Added)
+	assert.Equal(t, []string{"D"}, diff.Removed)
+	assert.Equal(t, []string{"C"}, diff.Changed)
+	assert.False(t, diff.Empty())
+	assert.True(t, diffEnv(want, want).Empty())
+}
@@ -1,13 +1,18 @@
 package app
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"maps"
+	"slices"
 	"sort"
+	"strings"
+	"sync"
 	"time"
 
-	"github.com/lex-unix/faino/internal/config"
 	"github.com/lex-unix/faino/internal/exec/sshexec"
 	"github.com/lex-unix/faino/internal/logging"
 	"github.com/lex-unix/faino/internal/txman"
@@ -15,11 +20,64 @@ import (
 
 const (
 	defautlHistoryFilePath = "~/.faino/history.json"
+
+	// historySchemaVersion is the version of the History record format written by faino.
+	// Records without a schema (version 1) only hold version and timestamp.
+	historySchemaVersion = 2
 )
 
+// Deploy outcomes recorded in history
+const (
+	HistoryStatusSuccess    = "success"
+	HistoryStatusRolledBack = "rolled_back"
+	HistoryStatusFailed     = "failed"
+	HistoryStatusCanary     = "canary"
+)
+
+// HistoryStatuses lists every status recorded in history.
+var HistoryStatuses = []string{HistoryStatusSuccess, HistoryStatusRolledBack, HistoryStatusFailed, HistoryStatusCanary}
+
 type History struct {
-	Version   string    `json:"version"`
-	Timestamp time.Time `json:"timestamp"`
+	Schema      int           `json:"schema,omitempty"`
+	Version     string        `json:"version"`
+	Commit      string        `json:"commit,omitempty"`
+	Branch      string        `json:"branch,omitempty"`
+	Message     string        `json:"message,omitempty"`
+	Author      string        `json:"author,omitempty"`
+	ImageDigest string        `json:"image_digest,omitempty"`
+	Duration    time.Duration `json:"duration,omitempty"`
+	Status      string        `json:"status,omitempty"`
+	Hosts       []string      `json:"hosts,omitempty"`
+	Timestamp   time.Time     `json:"timestamp"`
+}
+
+// Succeeded reports whether the entry describes a version that was deployed successfully.
+func (h History) Succeeded() bool {
+	return h.Status == HistoryStatusSuccess
+}
+
+// HistoryFilter narrows down history entries. Zero values match everything.
+type HistoryFilter struct {
+	Author string
+	Status string
+	Since  time.Time
+	Until  time.Time
+}
+
+func (f HistoryFilter) match(h History) bool {
+	if f.Author != "" && !strings.EqualFold(f.Author, h.Author) {
+		return false
+	}
+	if f.Status != "" && f.Status != h.Status {
+		return false
+	}
+	if !f.Since.IsZero() && h.Timestamp.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && h.Timestamp.After(f.Until) {
+		return false
+	}
+	return true
 }
 
 // ByDateAsc is a helper type for History slice that implements sort.Interface
@@ -36,55 +94,347 @@ func (a ByDateDesc) Len() int           { return len(a) }
 func (a ByDateDesc) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
 func (a ByDateDesc) Less(i, j int) bool { return a[i].Timestamp.After(a[j].Timestamp) }
 
+// LoadHistory reads the history of every host. If histories differ between hosts,
+// the differences are logged as warnings and the most up to date history is used.
+// Commands that change state use loadConsistentHistory instead.
 func (app *App) LoadHistory(ctx context.Context) error {
 	if app.history != nil {
 		return nil
 	}
-	servers := config.Get().Servers
-	resultsCh := make(chan RemoteFileContent, len(servers))
-	defer close(resultsCh)
-	err := app.txmanager.Execute(ctx, ReadRemoteFile(app.historyFilePath, resultsCh))
+
+	historyByHost, err := app.readHistories(ctx)
 	if err != nil {
 		return err
 	}
 
+	reference := referenceHost(historyByHost)
+	app.historyDivergence = nil
+	if diffs := diffHistories(historyByHost, reference); len(diffs) > 0 {
+		for _, diff := range diffs {
+			logging.WarnHostf(diff.Host, "history differs from %s: %s", reference, diff)
+		}
+		app.historyDivergence = &HistoryDivergenceError{Reference: reference, Diffs: diffs}
+	}
+
+	app.history = historyByHost[reference]
+	app.historySorted = false
+	app.sortHistory()
+	app.hostHistory = historyByHost
+	return nil
+}
+
+// loadConsistentHistory reads the history of every host like LoadHistory, but fails
+// if histories differ between hosts, as it is unclear what runs where.
+func (app *App) loadConsistentHistory(ctx context.Context) error {
+	if err := app.LoadHistory(ctx); err != nil {
+		return err
+	}
+	if app.historyDivergence != nil {
+		return app.historyDivergence
+	}
+	return nil
+}
+
+// historyOn returns the history of host, or the app history if the history of host was not read.
+func (app *App) historyOn(host string) []History {
+	if h, ok := app.hostHistory[host]; ok {
+		return h
+	}
+	return app.history
+}
+
+// currentVersionOn returns the version that runs on host according to its history.
+func (app *App) currentVersionOn(host string) string {
+	return latestVersion(app.historyOn(host))
+}
+
+// addHostHistory adds entry to the in-memory history of hosts.
+func (app *App) addHostHistory(entry History, hosts ...string) {
+	if app.hostHistory == nil {
+		app.hostHistory = make(map[string][]History)
+	}
+	for _, host := range hosts {
+		app.hostHistory[host] = append(slices.Clone(app.historyOn(host)), entry)
+	}
+}
+
+// readHistories reads and parses the history file on every host.
+func (app *App) readHistories(ctx context.Context) (map[string][]History, error) {
+	var mu sync.Mutex
 	contentByHost := make(map[string][]byte)
-	for range len(servers) {
-		select {
-		case <-ctx.Done():
-			return fmt.Errorf("context timeout or canceled")
-		case result := <-resultsCh:
-			if result.err == nil {
-				contentByHost[result.host] = result.data
-			} else {
-				logging.ErrorHostf(result.host, "failed to read remote file %s: %s", app.historyFilePath, result.err)
+	err := app.txmanager.Execute(ctx, func(ctx context.Context, client sshexec.Service) error {
+		data, err := client.ReadFile(app.historyFilePath)
+		if err != nil {
+			logging.ErrorHostf(client.Host(), "failed to read remote file %s: %s", app.historyFilePath, err)
+			return fmt.Errorf("host %s: failed to read file %q: %w", client.Host(), app.historyFilePath, err)
+		}
+		mu.Lock()
+		contentByHost[client.Host()] = data
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	hosts := app.txmanager.Hosts()
+	if len(hosts) != len(contentByHost) || len(contentByHost) == 0 {
+		return nil, fmt.Errorf("expected to read file on %d hosts, but got %d", len(hosts), len(contentByHost))
+	}
+
+	historyByHost := make(map[string][]History, len(contentByHost))
+	for host, data := range contentByHost {
+		h, err := parseHistory(data)
+		if err != nil {
+			return nil, fmt.Errorf("host %s: %w", host, err)
+		}
+		historyByHost[host] = h
+	}
+
+	return historyByHost, nil
+}
+
+// SyncHistory overwrites the history file on every host that differs from
+// the history on source. If source is empty, the most up to date host is used.
+// Files are written in a transaction while holding the deploy lock and are restored if any host fails.
+// It returns the hosts whose history was rewritten.
+func (app *App) SyncHistory(ctx context.Context, source string) ([]string, error) {
+	var synced []string
+	err := app.withLock(ctx, "history sync", func() error {
+		var err error
+		synced, err = app.syncHistory(ctx, source)
+		return err
+	})
+	return synced, err
+}
+
+func (app *App) syncHistory(ctx context.Context, source string) ([]string, error) {
+	historyByHost, err := app.readHistories(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if source == "" {
+		source = referenceHost(historyByHost)
+	}
+	if _, ok := historyByHost[source]; !ok {
+		return nil, fmt.Errorf("host %s is not one of the target hosts", source)
+	}
+
+	diffs := diffHistories(historyByHost, source)
+	if len(diffs) == 0 {
+		return nil, nil
+	}
+
+	data, err := encodeHistory(historyByHost[source])
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal history: %w", err)
+	}
+
+	original := make(map[string][]byte, len(diffs))
+	lagging := make([]string, 0, len(diffs))
+	for _, diff := range diffs {
+		raw, err := encodeHistory(historyByHost[diff.Host])
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal history: %w", err)
+		}
+		original[diff.Host] = raw
+		lagging = append(lagging, diff.Host)
+	}
+
+	rollback, err := app.txmanager.BeginTransaction(ctx, func(ctx context.Context, tx txman.Transaction) error {
+		return tx.Do(ctx, writeHostFile(app.historyFilePath, data, lagging), writeHostFiles(app.historyFilePath, original))
+	})
+	if err != nil {
+		logging.Info("initiating rollback...")
+		rollbackCtx, rollbackCancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer rollbackCancel()
+		if rollbackErr := rollback(rollbackCtx); rollbackErr != nil {
+			return nil, errors.Join(err, rollbackErr)
+		}
+		return nil, fmt.Errorf("history sync failed and was reverted: %w", err)
+	}
+
+	app.history = nil
+	return lagging, nil
+}
+
+// writeHostFile writes data to path only on the given hosts.
+func writeHostFile(path string, data []byte, hosts []string) txman.Callback {
+	return func(ctx context.Context, client sshexec.Service) error {
+		if !slices.Contains(hosts, client.Host()) {
+			return nil
+		}
+		return client.WriteFile(path, data)
+	}
+}
+
+// writeHostFiles writes the data of each host present in dataByHost to path.
+func writeHostFiles(path string, dataByHost map[string][]byte) txman.Callback {
+	return func(ctx context.Context, client sshexec.Service) error {
+		data, ok := dataByHost[client.Host()]
+		if !ok {
+			return nil
+		}
+		return client.WriteFile(path, data)
+	}
+}
+
+// HistoryDiff describes how the history on a host differs from the reference history.
+type HistoryDiff struct {
+	Host string
+	// Latest is the current version on the host.
+	Latest string
+	// ReferenceLatest is the current version on the reference host.
+	ReferenceLatest string
+	// Missing holds versions recorded on the reference host but not on this host.
+	Missing []string
+	// Extra holds versions recorded on this host but not on the reference host.
+	Extra []string
+}
+
+func (d HistoryDiff) String() string {
+	var parts []string
+	if d.Latest != d.ReferenceLatest {
+		parts = append(parts, fmt.Sprintf("current version is %q instead of %q", d.Latest, d.ReferenceLatest))
+	}
+	if len(d.Missing) > 0 {
+		parts = append(parts, fmt.Sprintf("missing versions %s", strings.Join(d.Missing, ", ")))
+	}
+	if len(d.Extra) > 0 {
+		parts = append(parts, fmt.Sprintf("unknown versions %s", strings.Join(d.Extra, ", ")))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// HistoryDivergenceError is returned when history files differ between hosts.
+type HistoryDivergenceError struct {
+	Reference string
+	Diffs     []HistoryDiff
+}
+
+func (e *HistoryDivergenceError) Error() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("history on %d host(s) differs from %s, run `faino history sync` to reconcile:", len(e.Diffs), e.Reference))
+	for _, diff := range e.Diffs {
+		sb.WriteString(fmt.Sprintf("\n  %s: %s", diff.Host, diff))
+	}
+	return sb.String()
+}
+
+// referenceHost returns the host with the most up to date history: the one
+// with the newest entry, then the one with most entries, then the first by name.
+func referenceHost(historyByHost map[string][]History) string {
+	var reference string
+	var referenceLatest time.Time
+	for _, host := range slices.Sorted(maps.Keys(historyByHost)) {
+		h := historyByHost[host]
+		var latest time.Time
+		for _, entry := range h {
+			if entry.Timestamp.After(latest) {
+				latest = entry.Timestamp
 			}
 		}
+		if reference == "" ||
+			latest.After(referenceLatest) ||
+			(latest.Equal(referenceLatest) && len(h) > len(historyByHost[reference])) {
+			reference = host
+			referenceLatest = latest
+		}
 	}
+	return reference
+}
 
-	if len(servers) != len(contentByHost) || len(contentByHost) == 0 {
-		return fmt.Errorf("expected to read file on %d hosts, but got %d", len(servers), len(contentByHost))
+// diffHistories compares history of every host with the history on reference
+// and returns differences sorted by host name.
+func diffHistories(historyByHost map[string][]History, reference string) []HistoryDiff {
+	ref := historyByHost[reference]
+	refLatest := latestVersion(ref)
+
+	var diffs []HistoryDiff
+	for _, host := range slices.Sorted(maps.Keys(historyByHost)) {
+		if host == reference {
+			continue
+		}
+		h := historyByHost[host]
+		if sameDeploys(h, ref) {
+			continue
+		}
+		diff := HistoryDiff{
+			Host:            host,
+			Latest:          latestVersion(h),
+			ReferenceLatest: refLatest,
+			Missing:         versionsNotIn(ref, h),
+			Extra:           versionsNotIn(h, ref),
+		}
+		if diff.Latest != diff.ReferenceLatest || len(diff.Missing) > 0 || len(diff.Extra) > 0 {
+			diffs = append(diffs, diff)
+		}
 	}
+	return diffs
+}
 
-	// TODO: compare histories from hosts and choose the first one if okay
-	var contents []byte
-	for _, data := range contentByHost {
-		contents = data
-		break
+// sameDeploys reports whether a and b record the same deploys. Their statuses may differ,
+// as a deploy that succeeded only partially is recorded as rolled back on the failed hosts.
+func sameDeploys(a, b []History) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, entry := range a {
+		if !slices.ContainsFunc(b, func(h History) bool {
+			return h.Version == entry.Version && h.Timestamp.Equal(entry.Timestamp)
+		}) {
+			return false
+		}
 	}
+	return true
+}
 
-	return app.loadHistory(contents)
+// versionsNotIn returns successfully deployed versions from a that are not deployed in b.
+func versionsNotIn(a, b []History) []string {
+	var versions []string
+	for _, entry := range a {
+		if !entry.Succeeded() {
+			continue
+		}
+		if !slices.ContainsFunc(b, func(h History) bool { return h.Succeeded() && h.Version == entry.Version }) {
+			versions = append(versions, entry.Version)
+		}
+	}
+	return versions
 }
 
-func (app *App) loadHistory(raw []byte) error {
+// latestVersion returns the most recent successfully deployed version.
+func latestVersion(h []History) string {
+	var latest History
+	for _, entry := range h {
+		if entry.Succeeded() && entry.Timestamp.After(latest.Timestamp) {
+			latest = entry
+		}
+	}
+	return latest.Version
+}
+
+// parseHistory decodes a history file. An empty file holds no history.
+// Records written before the schema was introduced only describe successful deploys.
+func parseHistory(raw []byte) ([]History, error) {
 	var h []History
-	err := json.Unmarshal(raw, &h)
-	if err != nil {
-		return fmt.Errorf("corrupted history file: %w", err)
+	if len(bytes.TrimSpace(raw)) == 0 {
+		return []History{}, nil
 	}
-	app.history = h
-	app.sortHistory()
-	return nil
+	if err := json.Unmarshal(raw, &h); err != nil {
+		return nil, fmt.Errorf("corrupted history file: %w", err)
+	}
+	for i := range h {
+		if h[i].Schema == 0 && h[i].Status == "" {
+			h[i].Status = HistoryStatusSuccess
+		}
+	}
+	return h, nil
+}
+
+func encodeHistory(h []History) ([]byte, error) {
+	return json.Marshal(h)
 }
 
 // sortHistory sorts history in descending order and modifies history slice.
@@ -98,30 +448,107 @@ func (app *App) sortHistory() {
 	app.historySorted = true
 }
 
-func (app *App) AppendVersion(version string) txman.Callback {
-	h := History{
-		Version:   version,
-		Timestamp: time.Now(),
+// deployedVersions returns the versions of history entries that succeeded, most recent first.
+func deployedVersions(history []History) []string {
+	sorted := slices.Clone(history)
+	sort.Sort(ByDateDesc(sorted))
+	var versions []string
+	for _, entry := range sorted {
+		if entry.Succeeded() && !slices.Contains(versions, entry.Version) {
+			versions = append(versions, entry.Version)
+		}
 	}
-	app.history = append(app.history, h)
-	app.historySorted = false
-	data, marshalErr := json.Marshal(app.history)
+	return versions
+}
 
+// AppendHistory returns a callback that writes the history of the host extended with entry to the host.
+// The in-memory history is not modified.
+func (app *App) AppendHistory(entry History) txman.Callback {
 	return func(ctx context.Context, client sshexec.Service) error {
-		if marshalErr != nil {
-			return fmt.Errorf("failed to marshal history: %w", marshalErr)
+		data, err := encodeHistory(append(slices.Clone(app.historyOn(client.Host())), entry))
+		if err != nil {
+			return fmt.Errorf("failed to marshal history: %w", err)
 		}
 		return client.WriteFile(app.historyFilePath, data)
 	}
 }
 
+// moveHistory returns a callback that moves the deploy of version at deployedAt to now
+// with status in the history of the host. With a successful status it makes version
+// the current version there.
+func (app *App) moveHistory(version string, deployedAt, now time.Time, status string) txman.Callback {
+	return func(ctx context.Context, client sshexec.Service) error {
+		history := slices.Clone(app.historyOn(client.Host()))
+		for i := range history {
+			if history[i].Version == version && history[i].Timestamp.Equal(deployedAt) {
+				history[i].Timestamp = now
+				history[i].Status = status
+			}
+		}
+		data, err := encodeHistory(history)
+		if err != nil {
+			return fmt.Errorf("failed to marshal history: %w", err)
+		}
+		return client.WriteFile(app.historyFilePath, data)
+	}
+}
+
+// recordPartial records entry as rolled back in the history of the hosts that failed a deploy
+// that succeeded on others, so that their history matches the version they keep running.
+func (app *App) recordPartial(ctx context.Context, entry History, partialErr *txman.PartialError) error {
+	failed := slices.Sorted(maps.Keys(partialErr.Errs))
+	failedTx, err := app.txmanager.Subset(failed...)
+	if err != nil {
+		return err
+	}
+	entry.Status = HistoryStatusRolledBack
+	if err := failedTx.Execute(ctx, app.AppendHistory(entry)); err != nil {
+		return fmt.Errorf("failed to record rolled back deploy of %s in history: %w", entry.Version, err)
+	}
+	app.addHostHistory(entry, failed...)
+	return nil
+}
+
+// recordPartialRollback records the rollback to version as rolled back in the history of
+// the hosts that failed a rollback that succeeded on others. The deploy of version is moved
+// to now like on the other hosts, so histories keep recording the same deploys.
+func (app *App) recordPartialRollback(ctx context.Context, version string, deployedAt, now time.Time, partialErr *txman.PartialError) error {
+	failedTx, err := app.txmanager.Subset(slices.Sorted(maps.Keys(partialErr.Errs))...)
+	if err != nil {
+		return err
+	}
+	err = failedTx.Execute(ctx, app.moveHistory(version, deployedAt, now, HistoryStatusRolledBack))
+	if err != nil {
+		return fmt.Errorf("failed to record rolled back rollback to %s in history: %w", version, err)
+	}
+	return nil
+}
+
+// deployedOnAnyHost reports whether the deploy recorded by entry succeeded on any host.
+// A deploy may succeed only on some hosts, so its status differs between host histories.
+func (app *App) deployedOnAnyHost(entry History) bool {
+	if entry.Succeeded() {
+		return true
+	}
+	for _, history := range app.hostHistory {
+		if slices.ContainsFunc(history, func(h History) bool {
+			return h.Succeeded() && h.Version == entry.Version && h.Timestamp.Equal(entry.Timestamp)
+		}) {
+			return true
+		}
+	}
+	return false
+}
+
 func (app *App) LatestVersion() string {
 	app.sortHistory()
 	if app.history == nil {
 		return ""
 	}
-	if len(app.history) == 0 {
-		return ""
+	for _, entry := range app.history {
+		if entry.Succeeded() {
+			return entry.Version
+		}
 	}
-	return app.history[0].Version
+	return ""
 }
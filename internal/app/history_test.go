@@ -5,10 +5,15 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/lex-unix/faino/internal/exec/localexec"
 	"github.com/lex-unix/faino/internal/exec/sshexec"
+	"github.com/lex-unix/faino/internal/exec/sshexec/sshexectest"
 	"github.com/lex-unix/faino/internal/txman"
 )
 
@@ -56,9 +61,9 @@ func (stub *StubTxman) SetPrimaryHost(_ string) error {
 }
 
 func TestHistorySort(t *testing.T) {
-	app := &App{}
-	err := app.loadHistory(testHistoryData)
+	history, err := parseHistory(testHistoryData)
 	assert.NoError(t, err)
+	app := &App{history: history}
 
 	app.sortHistory()
 
@@ -73,9 +78,9 @@ func TestHistorySort(t *testing.T) {
 }
 
 func TestHistoryLatestVersion(t *testing.T) {
-	app := &App{}
-	err := app.loadHistory(testHistoryData)
+	history, err := parseHistory(testHistoryData)
 	assert.NoError(t, err)
+	app := &App{history: history}
 
 	got := app.LatestVersion()
 	expected := "3"
@@ -85,9 +90,9 @@ func TestHistoryLatestVersion(t *testing.T) {
 // func TestHistoryAppend(t *testing.T) {
 // 	ssh := &StubSSH{}
 // 	txmanager := &StubTxman{ssh: ssh}
-// 	app := &App{txmanager: txmanager}
-// 	err := app.loadHistory(testHistoryData)
+// 	history, err := parseHistory(testHistoryData)
 // 	assert.NoError(t, err)
+// 	app := &App{txmanager: txmanager, history: history}
 //
 // 	initialLen := len(app.history)
 // 	appendedVersion := "4"
@@ -103,3 +108,278 @@ func TestHistoryLatestVersion(t *testing.T) {
 // 	assert.Len(t, app.history, initialLen+1)
 // 	assert.JSONEq(t, string(expectedJSONBytes), actualWrittenBytes)
 // }
+
+func TestDiffHistories(t *testing.T) {
+	full, err := parseHistory(testHistoryData)
+	assert.NoError(t, err)
+	lagging := []History{full[1], full[2]}
+
+	historyByHost := map[string][]History{
+		"host1": full,
+		"host2": lagging,
+		"host3": full,
+	}
+
+	reference := referenceHost(historyByHost)
+	assert.Equal(t, "host1", reference)
+
+	diffs := diffHistories(historyByHost, reference)
+	assert.Len(t, diffs, 1)
+	assert.Equal(t, "host2", diffs[0].Host)
+	assert.Equal(t, "2", diffs[0].Latest)
+	assert.Equal(t, "3", diffs[0].ReferenceLatest)
+	assert.Equal(t, []string{"3"}, diffs[0].Missing)
+	assert.Empty(t, diffs[0].Extra)
+
+	diffs = diffHistories(historyByHost, "host2")
+	assert.Len(t, diffs, 2)
+	assert.Equal(t, []string{"3"}, diffs[0].Extra)
+}
+
+func TestHistoryRecords(t *testing.T) {
+	raw := []byte(`[
+		{"version": "1", "timestamp": "2025-02-01T10:00:00.000Z"},
+		{"schema": 2, "version": "2", "author": "alice", "status": "success", "timestamp": "2025-03-01T10:00:00.000Z"},
+		{"schema": 2, "version": "3", "author": "bob", "status": "rolled_back", "timestamp": "2025-04-01T10:00:00.000Z"}
+	]`)
+
+	history, err := parseHistory(raw)
+	assert.NoError(t, err)
+	app := &App{history: history}
+	app.sortHistory()
+
+	t.Run("legacy records are successful deploys", func(t *testing.T) {
+		assert.Equal(t, HistoryStatusSuccess, app.history[2].Status)
+	})
+
+	t.Run("latest version skips unsuccessful deploys", func(t *testing.T) {
+		assert.Equal(t, "2", app.LatestVersion())
+	})
+
+	t.Run("filter matches author, status and date range", func(t *testing.T) {
+		count := func(f HistoryFilter) int {
+			n := 0
+			for _, h := range app.history {
+				if f.match(h) {
+					n++
+				}
+			}
+			return n
+		}
+		assert.Equal(t, 3, count(HistoryFilter{}))
+		assert.Equal(t, 1, count(HistoryFilter{Author: "Alice"}))
+		assert.Equal(t, 2, count(HistoryFilter{Status: HistoryStatusSuccess}))
+		since := time.Date(2025, 2, 15, 0, 0, 0, 0, time.UTC)
+		until := time.Date(2025, 3, 15, 0, 0, 0, 0, time.UTC)
+		assert.Equal(t, 1, count(HistoryFilter{Since: since, Until: until}))
+	})
+
+	t.Run("every recorded status can be filtered by", func(t *testing.T) {
+		for _, status := range []string{HistoryStatusSuccess, HistoryStatusRolledBack, HistoryStatusFailed, HistoryStatusCanary} {
+			assert.Contains(t, HistoryStatuses, status)
+			assert.True(t, HistoryFilter{Status: status}.match(History{Version: "4", Status: status}))
+		}
+		assert.False(t, HistoryFilter{Status: HistoryStatusCanary}.match(app.history[0]))
+	})
+}
+
+func TestActiveCanary(t *testing.T) {
+	raw := []byte(`[
+		{"schema": 2, "version": "1", "status": "success", "timestamp": "2025-02-01T10:00:00.000Z"},
+		{"schema": 2, "version": "2", "status": "canary", "hosts": ["host1"], "timestamp": "2025-03-01T10:00:00.000Z"}
+	]`)
+
+	history, err := parseHistory(raw)
+	assert.NoError(t, err)
+	app := &App{history: history}
+
+	canary, ok := app.activeCanary()
+	assert.True(t, ok)
+	assert.Equal(t, "2", canary.Version)
+	assert.Equal(t, "1", app.LatestVersion())
+
+	app.history = append(app.history, promotedEntry(canary))
+	app.historySorted = false
+	_, ok = app.activeCanary()
+	assert.False(t, ok)
+	assert.Equal(t, "2", app.LatestVersion())
+}
+
+func TestDeployedVersions(t *testing.T) {
+	raw := []byte(`[
+		{"schema": 2, "version": "1", "status": "success", "timestamp": "2025-02-01T10:00:00.000Z"},
+		{"schema": 2, "version": "2", "status": "success", "timestamp": "2025-03-01T10:00:00.000Z"},
+		{"schema": 2, "version": "3", "status": "rolled_back", "timestamp": "2025-04-01T10:00:00.000Z"},
+		{"schema": 2, "version": "1", "status": "success", "timestamp": "2025-05-01T10:00:00.000Z"}
+	]`)
+
+	history, err := parseHistory(raw)
+	assert.NoError(t, err)
+	app := &App{history: history}
+
+	assert.Equal(t, []string{"1", "2"}, deployedVersions(app.history))
+}
+
+func TestPartialDeployHistory(t *testing.T) {
+	t.Setenv("USER", "jane")
+	loadTestConfig(t, `
+service: app
+image: app
+servers: [host1, host2]
+registry:
+  username: user
+  password: password
+deploy:
+  rolling:
+    batch_size: 1
+    max_failures: 1
+`)
+
+	initial := []byte(`[{"schema": 2, "version": "v1", "status": "success", "timestamp": "2025-05-01T10:00:00.000Z"}]`)
+	newHost := func(host string, failRun bool) *sshexectest.Service {
+		client := sshexectest.New(host)
+		client.WriteFile(defautlHistoryFilePath, initial)
+		client.RunFunc = func(ctx context.Context, cmd string, stdin []byte) (string, error) {
+			switch {
+			case strings.HasPrefix(cmd, "docker ps"):
+				// the proxy is running
+				return "traefik\n", nil
+			case failRun && strings.HasPrefix(cmd, "docker run") && strings.Contains(cmd, "--name app-v2"):
+				return "", errors.New("container failed to start")
+			}
+			return "", nil
+		}
+		return client
+	}
+	host1 := newHost("host1", false)
+	host2 := newHost("host2", true)
+	newApp := func() *App {
+		return New(localexec.NewRecorder(nil), WithTxManager(txman.New(host1, host2)))
+	}
+
+	err := newApp().deploy(context.Background(), DeployOptions{Version: "v2"})
+	assert.ErrorContains(t, err, "deployed only partially")
+
+	raw1, _ := host1.ReadFile(defautlHistoryFilePath)
+	history1, err := parseHistory(raw1)
+	assert.NoError(t, err)
+	raw2, _ := host2.ReadFile(defautlHistoryFilePath)
+	history2, err := parseHistory(raw2)
+	assert.NoError(t, err)
+	assert.Len(t, history1, 2)
+	assert.Len(t, history2, 2)
+	assert.Equal(t, "v2", history1[1].Version)
+	assert.Equal(t, HistoryStatusSuccess, history1[1].Status)
+	assert.Equal(t, "v2", history2[1].Version)
+	assert.Equal(t, HistoryStatusRolledBack, history2[1].Status)
+	assert.True(t, history1[1].Timestamp.Equal(history2[1].Timestamp))
+
+	// the histories do not diverge and tell which version runs on each host
+	app := newApp()
+	assert.NoError(t, app.LoadHistory(context.Background()))
+	assert.Equal(t, "v2", app.currentVersionOn("host1"))
+	assert.Equal(t, "v1", app.currentVersionOn("host2"))
+}
+
+func TestPartialRollbackHistory(t *testing.T) {
+	t.Setenv("USER", "jane")
+	loadTestConfig(t, `
+service: app
+image: app
+servers: [host1, host2]
+registry:
+  username: user
+  password: password
+deploy:
+  rolling:
+    batch_size: 1
+    max_failures: 1
+`)
+
+	initial := []byte(`[
+		{"schema": 2, "version": "v1", "status": "success", "timestamp": "2025-05-01T10:00:00.000Z"},
+		{"schema": 2, "version": "v2", "status": "success", "timestamp": "2025-05-02T10:00:00.000Z"}
+	]`)
+	failRun := map[string]bool{"host2": true}
+	newHost := func(host string) *sshexectest.Service {
+		client := sshexectest.New(host)
+		client.WriteFile(defautlHistoryFilePath, initial)
+		client.RunFunc = func(ctx context.Context, cmd string, stdin []byte) (string, error) {
+			switch {
+			case strings.HasPrefix(cmd, "docker ps"):
+				// the proxy and the containers of both versions exist
+				return "traefik\n", nil
+			case failRun[host] && strings.HasPrefix(cmd, "docker run") && strings.Contains(cmd, "--name app-v1"):
+				return "", errors.New("container failed to start")
+			}
+			return "", nil
+		}
+		return client
+	}
+	host1 := newHost("host1")
+	host2 := newHost("host2")
+	newApp := func() *App {
+		return New(localexec.NewRecorder(nil), WithTxManager(txman.New(host1, host2)))
+	}
+
+	err := newApp().rollback(context.Background(), "v1")
+	assert.ErrorContains(t, err, "succeeded only partially")
+
+	raw1, _ := host1.ReadFile(defautlHistoryFilePath)
+	history1, err := parseHistory(raw1)
+	assert.NoError(t, err)
+	raw2, _ := host2.ReadFile(defautlHistoryFilePath)
+	history2, err := parseHistory(raw2)
+	assert.NoError(t, err)
+	assert.Equal(t, "v1", latestVersion(history1))
+	assert.Equal(t, "v2", latestVersion(history2))
+
+	// the histories do not diverge and tell which version runs on each host
+	app := newApp()
+	assert.NoError(t, app.LoadHistory(context.Background()))
+	assert.Equal(t, "v1", app.currentVersionOn("host1"))
+	assert.Equal(t, "v2", app.currentVersionOn("host2"))
+
+	t.Run("rollback is retried on the remaining hosts", func(t *testing.T) {
+		failRun["host2"] = false
+		before := len(host1.Cmds())
+		err := newApp().rollback(context.Background(), "v1")
+		assert.NoError(t, err)
+
+		app := newApp()
+		assert.NoError(t, app.LoadHistory(context.Background()))
+		assert.Equal(t, "v1", app.currentVersionOn("host1"))
+		assert.Equal(t, "v1", app.currentVersionOn("host2"))
+		assert.False(t, slices.ContainsFunc(host1.Cmds()[before:], func(cmd string) bool {
+			return strings.HasPrefix(cmd, "docker run")
+		}), "hosts that already run the version are not switched again")
+	})
+}
+
+func TestDivergedHistory(t *testing.T) {
+	host1 := sshexectest.New("host1")
+	host1.WriteFile(defautlHistoryFilePath, []byte(`[
+		{"schema": 2, "version": "1", "status": "success", "timestamp": "2025-02-01T10:00:00.000Z"},
+		{"schema": 2, "version": "2", "status": "success", "timestamp": "2025-03-01T10:00:00.000Z"}
+	]`))
+	host2 := sshexectest.New("host2")
+	host2.WriteFile(defautlHistoryFilePath, []byte(`[
+		{"schema": 2, "version": "1", "status": "success", "timestamp": "2025-02-01T10:00:00.000Z"}
+	]`))
+	newApp := func() *App {
+		return New(localexec.NewRecorder(nil), WithTxManager(txman.New(host1, host2)))
+	}
+
+	t.Run("read-only commands use the most up to date history", func(t *testing.T) {
+		history, err := newApp().History(context.Background(), "desc", HistoryFilter{})
+		assert.NoError(t, err)
+		assert.Len(t, history, 2)
+	})
+
+	t.Run("commands that change state refuse to run", func(t *testing.T) {
+		var divergenceErr *HistoryDivergenceError
+		err := newApp().loadConsistentHistory(context.Background())
+		assert.ErrorAs(t, err, &divergenceErr)
+		assert.Equal(t, "host1", divergenceErr.Reference)
+	})
+}
@@ -0,0 +1,117 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/lex-unix/faino/internal/config"
+	"github.com/lex-unix/faino/internal/exec/localexec"
+	"github.com/lex-unix/faino/internal/exec/sshexec"
+	"github.com/lex-unix/faino/internal/logging"
+	"github.com/lex-unix/faino/internal/txman"
+)
+
+// Hook stages, passed to hooks as FAINO_HOOK
+const (
+	hookPreBuild     = "pre-build"
+	hookPreDeploy    = "pre-deploy"
+	hookPostDeploy   = "post-deploy"
+	hookPreRollback  = "pre-rollback"
+	hookPostRollback = "post-rollback"
+)
+
+// hookContext describes the deploy or rollback that hooks run for.
+type hookContext struct {
+	service         string
+	version         string
+	previousVersion string
+	hosts           []string
+	performer       string
+}
+
+// env returns the variables hooks of stage get in their environment.
+func (hc hookContext) env(stage string) map[string]string {
+	return map[string]string{
+		"FAINO_HOOK":             stage,
+		"FAINO_SERVICE":          hc.service,
+		"FAINO_VERSION":          hc.version,
+		"FAINO_PREVIOUS_VERSION": hc.previousVersion,
+		"FAINO_HOSTS":            strings.Join(hc.hosts, ","),
+		"FAINO_PERFORMER":        hc.performer,
+	}
+}
+
+// runLocalHooks runs the local commands of hooks one by one and stops at the first one that fails.
+func (app *App) runLocalHooks(ctx context.Context, stage string, hooks []config.Hook, hc hookContext) error {
+	vars := hc.env(stage)
+	env := make([]string, 0, len(vars))
+	for _, k := range slices.Sorted(maps.Keys(vars)) {
+		env = append(env, fmt.Sprintf("%s=%s", k, vars[k]))
+	}
+	for _, hook := range hooks {
+		if hook.Local == "" {
+			continue
+		}
+		logging.Infof("running %s hook", stage)
+		if err := app.lexec.Run(ctx, hook.Local, localexec.WithEnv(env)); err != nil {
+			return fmt.Errorf("%s hook failed: %w", stage, err)
+		}
+	}
+	return nil
+}
+
+// remoteHooks registers steps that run the remote commands of hooks in one-off
+// containers of hc.version on the host of tx. A failing hook fails the transaction.
+func (app *App) remoteHooks(ctx context.Context, tx txman.Transaction, stage string, hooks []config.Hook, hc hookContext) error {
+	for _, hook := range hooks {
+		if hook.Remote == "" {
+			continue
+		}
+		err := tx.Do(ctx, app.remoteHook(stage, hook, hc), nil)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runRemoteHooks runs the remote commands of hooks on every host.
+func (app *App) runRemoteHooks(ctx context.Context, stage string, hooks []config.Hook, hc hookContext) error {
+	return app.txmanager.Execute(ctx, func(ctx context.Context, client sshexec.Service) error {
+		for _, hook := range hooks {
+			if hook.Remote == "" {
+				continue
+			}
+			if err := app.remoteHook(stage, hook, hc)(ctx, client); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (app *App) remoteHook(stage string, hook config.Hook, hc hookContext) txman.Callback {
+	roles := appRoles(config.Get())
+	return func(ctx context.Context, client sshexec.Service) error {
+		runCmd, ok := app.oneOffCmd(client.Host(), roles, hc.version, hook.Remote, hc.env(stage), false)
+		if !ok {
+			return nil
+		}
+		logging.InfoHostf(client.Host(), "running %s hook", stage)
+		if err := client.Run(ctx, runCmd); err != nil {
+			return fmt.Errorf("%s hook failed on %s: %w", stage, client.Host(), err)
+		}
+		return nil
+	}
+}
+
+// runPostHooks runs the remote and then the local commands of hooks.
+func (app *App) runPostHooks(ctx context.Context, stage string, hooks []config.Hook, hc hookContext) error {
+	if err := app.runRemoteHooks(ctx, stage, hooks, hc); err != nil {
+		return err
+	}
+	return app.runLocalHooks(ctx, stage, hooks, hc)
+}
@@ -0,0 +1,39 @@
+package app
+
+import (
+	"context"
+	"testing"
+
+	"github.com/lex-unix/faino/internal/config"
+	"github.com/lex-unix/faino/internal/exec/localexec"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunLocalHooks(t *testing.T) {
+	app := New(localexec.New())
+	hc := hookContext{
+		service:         "app",
+		version:         "v2",
+		previousVersion: "v1",
+		hosts:           []string{"a", "b"},
+		performer:       "jane",
+	}
+
+	t.Run("passes deploy details in env", func(t *testing.T) {
+		hooks := []config.Hook{
+			{Local: `test "$FAINO_HOOK" = pre-deploy -a "$FAINO_VERSION" = v2 -a "$FAINO_PREVIOUS_VERSION" = v1`},
+			{Local: `test "$FAINO_HOSTS" = a,b -a "$FAINO_PERFORMER" = jane -a "$FAINO_SERVICE" = app`},
+			{Remote: "exit 1"},
+		}
+		assert.NoError(t, app.runLocalHooks(context.Background(), hookPreDeploy, hooks, hc))
+	})
+
+	t.Run("stops at failing hook", func(t *testing.T) {
+		hooks := []config.Hook{
+			{Local: "exit 1"},
+			{Local: "exit 0"},
+		}
+		err := app.runLocalHooks(context.Background(), hookPreBuild, hooks, hc)
+		assert.ErrorContains(t, err, "pre-build hook failed")
+	})
+}
@@ -0,0 +1,173 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/lex-unix/faino/internal/command"
+	"github.com/lex-unix/faino/internal/exec/sshexec"
+	"github.com/lex-unix/faino/internal/logging"
+	"github.com/lex-unix/faino/internal/txman"
+)
+
+const (
+	defaultLockDir  = "~/.faino/lock"
+	lockDetailsFile = "details.json"
+)
+
+// Lock describes who holds the deploy lock on a host and why.
+type Lock struct {
+	Holder    string    `json:"holder"`
+	Machine   string    `json:"machine,omitempty"`
+	Reason    string    `json:"reason"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+func (l Lock) String() string {
+	s := fmt.Sprintf("held by %s", l.Holder)
+	if l.Machine != "" {
+		s += fmt.Sprintf(" (%s)", l.Machine)
+	}
+	s += fmt.Sprintf(" since %s", l.Timestamp.Local().Format(time.DateTime))
+	if l.Reason != "" {
+		s += fmt.Sprintf(": %s", l.Reason)
+	}
+	return s
+}
+
+// LockedError is returned when the deploy lock is already held on a host.
+type LockedError struct {
+	Host string
+	Lock Lock
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("deploy lock on %s is %s", e.Host, e.Lock)
+}
+
+// AcquireLock acquires the deploy lock on every host. The lock is acquired in a
+// transaction, so if any host is already locked, locks taken on other hosts are released.
+func (app *App) AcquireLock(ctx context.Context, reason string) error {
+	machine, _ := os.Hostname()
+	lock := Lock{
+		Holder:    app.performer(ctx),
+		Machine:   machine,
+		Reason:    reason,
+		Timestamp: time.Now(),
+	}
+	data, err := json.Marshal(lock)
+	if err != nil {
+		return fmt.Errorf("failed to marshal lock: %w", err)
+	}
+
+	rollback, err := app.txmanager.BeginTransaction(ctx, func(ctx context.Context, tx txman.Transaction) error {
+		return tx.Do(ctx, app.acquireLock(data), app.releaseLock())
+	})
+	if err != nil {
+		rollbackCtx, rollbackCancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer rollbackCancel()
+		if rollbackErr := rollback(rollbackCtx); rollbackErr != nil {
+			return errors.Join(err, rollbackErr)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// ReleaseLock releases the deploy lock on every host. Unless force is set,
+// it refuses to release a lock that is held by someone else.
+func (app *App) ReleaseLock(ctx context.Context, force bool) error {
+	if !force {
+		locks, err := app.LockStatus(ctx)
+		if err != nil {
+			return err
+		}
+		holder := app.performer(ctx)
+		for host, lock := range locks {
+			if lock != nil && lock.Holder != holder {
+				return fmt.Errorf("%w, use --force to release it", &LockedError{Host: host, Lock: *lock})
+			}
+		}
+	}
+
+	return app.txmanager.Execute(ctx, app.releaseLock())
+}
+
+// LockStatus returns the deploy lock on every host, nil meaning the host is not locked.
+func (app *App) LockStatus(ctx context.Context) (map[string]*Lock, error) {
+	var mu sync.Mutex
+	locks := make(map[string]*Lock)
+	err := app.txmanager.Execute(ctx, func(ctx context.Context, client sshexec.Service) error {
+		lock, err := app.readLock(ctx, client)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		locks[client.Host()] = lock
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return locks, nil
+}
+
+// withLock runs fn while holding the deploy lock. The lock is released once fn
+// returns, including when ctx is canceled.
+func (app *App) withLock(ctx context.Context, reason string, fn func() error) error {
+	if err := app.AcquireLock(ctx, reason); err != nil {
+		return err
+	}
+	defer func() {
+		releaseCtx, releaseCancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer releaseCancel()
+		if err := app.txmanager.Execute(releaseCtx, app.releaseLock()); err != nil {
+			logging.Errorf("failed to release deploy lock, run `faino lock release` to release it: %s", err)
+		}
+	}()
+
+	return fn()
+}
+
+func (app *App) acquireLock(details []byte) txman.Callback {
+	return func(ctx context.Context, client sshexec.Service) error {
+		err := client.Run(ctx, command.AcquireLock(app.lockDir, lockDetailsFile), sshexec.WithStdin(bytes.NewReader(details)))
+		if err == nil {
+			return nil
+		}
+		if lock, readErr := app.readLock(ctx, client); readErr == nil && lock != nil {
+			return &LockedError{Host: client.Host(), Lock: *lock}
+		}
+		return fmt.Errorf("failed to acquire deploy lock on %s: %w", client.Host(), err)
+	}
+}
+
+func (app *App) releaseLock() txman.Callback {
+	return func(ctx context.Context, client sshexec.Service) error {
+		return client.Run(ctx, command.ReleaseLock(app.lockDir))
+	}
+}
+
+func (app *App) readLock(ctx context.Context, client sshexec.Service) (*Lock, error) {
+	var out bytes.Buffer
+	err := client.Run(ctx, command.LockDetails(app.lockDir, lockDetailsFile), sshexec.WithStdout(&out))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read deploy lock on %s: %w", client.Host(), err)
+	}
+	if len(bytes.TrimSpace(out.Bytes())) == 0 {
+		return nil, nil
+	}
+	var lock Lock
+	if err := json.Unmarshal(out.Bytes(), &lock); err != nil {
+		return nil, fmt.Errorf("corrupted deploy lock on %s: %w", client.Host(), err)
+	}
+	return &lock, nil
+}
@@ -0,0 +1,143 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lex-unix/faino/internal/command"
+	"github.com/lex-unix/faino/internal/exec/localexec"
+	"github.com/lex-unix/faino/internal/exec/sshexec"
+	"github.com/lex-unix/faino/internal/exec/sshexec/sshexectest"
+	"github.com/lex-unix/faino/internal/txman"
+	"github.com/stretchr/testify/assert"
+)
+
+var testLockTime = time.Date(2025, 5, 1, 10, 0, 0, 0, time.Local)
+
+// lockedHost returns a host whose deploy lock is held as described by details,
+// or not held at all if details is empty.
+func lockedHost(host, details string) *sshexectest.Service {
+	client := sshexectest.New(host)
+	client.RunFunc = func(ctx context.Context, cmd string, stdin []byte) (string, error) {
+		switch cmd {
+		case command.LockDetails(defaultLockDir, lockDetailsFile):
+			return details, nil
+		case command.AcquireLock(defaultLockDir, lockDetailsFile):
+			if details != "" {
+				return "", errors.New("mkdir: cannot create directory: File exists")
+			}
+		}
+		return "", nil
+	}
+	return client
+}
+
+// newLockTestApp returns an App on hosts whose performer is jane.
+func newLockTestApp(t *testing.T, hosts ...*sshexectest.Service) *App {
+	t.Setenv("USER", "jane")
+	conns := make([]sshexec.Service, len(hosts))
+	for i, host := range hosts {
+		conns[i] = host
+	}
+	// git is not run by a recorder without runner, so the performer falls back to $USER
+	return New(localexec.NewRecorder(nil), WithTxManager(txman.New(conns...)))
+}
+
+func TestLockString(t *testing.T) {
+	lock := Lock{Holder: "jane", Machine: "laptop", Reason: "deploy", Timestamp: testLockTime}
+	assert.Equal(t, "held by jane (laptop) since 2025-05-01 10:00:00: deploy", lock.String())
+
+	lock = Lock{Holder: "jane", Timestamp: testLockTime}
+	assert.Equal(t, "held by jane since 2025-05-01 10:00:00", lock.String())
+
+	err := &LockedError{Host: "host1", Lock: lock}
+	assert.Equal(t, "deploy lock on host1 is held by jane since 2025-05-01 10:00:00", err.Error())
+}
+
+func TestReadLock(t *testing.T) {
+	app := New(localexec.NewRecorder(nil))
+	ctx := context.Background()
+
+	lock, err := app.readLock(ctx, lockedHost("host1", ""))
+	assert.NoError(t, err)
+	assert.Nil(t, lock)
+
+	lock, err = app.readLock(ctx, lockedHost("host1", `{"holder": "jane", "reason": "deploy"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, &Lock{Holder: "jane", Reason: "deploy"}, lock)
+
+	_, err = app.readLock(ctx, lockedHost("host1", "{"))
+	assert.ErrorContains(t, err, "corrupted deploy lock on host1")
+}
+
+func TestAcquireLock(t *testing.T) {
+	// the locked host fails once the free one holds the lock, so that it is not canceled
+	acquired := make(chan struct{})
+	free := lockedHost("host1", "")
+	freeRun := free.RunFunc
+	free.RunFunc = func(ctx context.Context, cmd string, stdin []byte) (string, error) {
+		out, err := freeRun(ctx, cmd, stdin)
+		if cmd == command.AcquireLock(defaultLockDir, lockDetailsFile) {
+			close(acquired)
+		}
+		return out, err
+	}
+	locked := lockedHost("host2", `{"holder": "john", "reason": "deploy"}`)
+	lockedRun := locked.RunFunc
+	locked.RunFunc = func(ctx context.Context, cmd string, stdin []byte) (string, error) {
+		if cmd == command.AcquireLock(defaultLockDir, lockDetailsFile) {
+			<-acquired
+		}
+		return lockedRun(ctx, cmd, stdin)
+	}
+	app := newLockTestApp(t, free, locked)
+
+	err := app.AcquireLock(context.Background(), "deploy")
+
+	var lockedErr *LockedError
+	assert.ErrorAs(t, err, &lockedErr)
+	assert.Equal(t, "host2", lockedErr.Host)
+	assert.Equal(t, "john", lockedErr.Lock.Holder)
+	// the lock taken on the free host is released again
+	assert.Contains(t, free.Cmds(), command.ReleaseLock(defaultLockDir))
+	assert.NotContains(t, locked.Cmds(), command.ReleaseLock(defaultLockDir))
+}
+
+func TestReleaseLock(t *testing.T) {
+	ctx := context.Background()
+	released := func(host *sshexectest.Service) bool {
+		for _, cmd := range host.Cmds() {
+			if strings.HasPrefix(cmd, "rm -rf") {
+				return true
+			}
+		}
+		return false
+	}
+
+	t.Run("releases own lock", func(t *testing.T) {
+		host := lockedHost("host1", `{"holder": "jane"}`)
+		app := newLockTestApp(t, host)
+		assert.NoError(t, app.ReleaseLock(ctx, false))
+		assert.True(t, released(host))
+	})
+
+	t.Run("refuses to release lock held by someone else", func(t *testing.T) {
+		host := lockedHost("host1", `{"holder": "john"}`)
+		app := newLockTestApp(t, host)
+		err := app.ReleaseLock(ctx, false)
+		var lockedErr *LockedError
+		assert.ErrorAs(t, err, &lockedErr)
+		assert.ErrorContains(t, err, "use --force")
+		assert.False(t, released(host))
+	})
+
+	t.Run("releases lock held by someone else with force", func(t *testing.T) {
+		host := lockedHost("host1", `{"holder": "john"}`)
+		app := newLockTestApp(t, host)
+		assert.NoError(t, app.ReleaseLock(ctx, true))
+		assert.True(t, released(host))
+	})
+}
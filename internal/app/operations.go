@@ -1,11 +1,17 @@
 package app
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/lex-unix/faino/internal/command"
+	"github.com/lex-unix/faino/internal/config"
 	"github.com/lex-unix/faino/internal/exec/sshexec"
+	"github.com/lex-unix/faino/internal/logging"
 	"github.com/lex-unix/faino/internal/txman"
 )
 
@@ -15,43 +21,181 @@ func PullImage(img string) txman.Callback {
 	}
 }
 
-func RunContainer(img, container string, env []string) txman.Callback {
+func RunContainer(img, container string, env, labels, options []string, cmd string) txman.Callback {
 	return func(ctx context.Context, client sshexec.Service) error {
-		return client.Run(ctx, command.RunContainer(img, container, env))
+		return client.Run(ctx, command.RunContainer(img, container, env, labels, options, cmd))
 	}
 }
 
-func StopContainer(containerName string) txman.Callback {
+// proxyHealthRetries is how many times the proxy health check runs before giving up
+const proxyHealthRetries = 10
+
+// Docker health statuses reported by `docker inspect`
+const (
+	healthHealthy   = "healthy"
+	healthUnhealthy = "unhealthy"
+	healthNone      = "none"
+)
+
+// WaitForHealthy polls the health status docker reports for container until it is
+// healthy. It fails if docker marks the container unhealthy or the status does not
+// settle within the time docker needs to exhaust the configured retries, reporting
+// the output of the last check. Containers without a health check are considered
+// healthy unless hc is enabled.
+func WaitForHealthy(container string, hc config.Healthcheck) txman.Callback {
 	return func(ctx context.Context, client sshexec.Service) error {
-		return client.Run(ctx, command.StopContainer(containerName))
+		deadline := time.Now().Add(hc.StartPeriod + time.Duration(hc.Retries+1)*(hc.Interval+hc.Timeout))
+		for {
+			var out bytes.Buffer
+			err := client.Run(ctx, command.ContainerHealth(container), sshexec.WithStdout(&out))
+			if err != nil {
+				return fmt.Errorf("failed to inspect health of container %s: %w", container, err)
+			}
+
+			switch status := strings.TrimSpace(out.String()); status {
+			case healthHealthy:
+				logging.InfoHostf(client.Host(), "container %s is healthy", container)
+				return nil
+			case "":
+				// no status is reported in dry-run mode
+				return nil
+			case healthNone:
+				if hc.Enabled() {
+					return fmt.Errorf("container %s has no health check, although one is configured", container)
+				}
+				return nil
+			case healthUnhealthy:
+				return fmt.Errorf("container %s is unhealthy%s", container, lastHealthOutput(ctx, client, container))
+			default:
+				if time.Now().After(deadline) {
+					return fmt.Errorf("container %s did not become healthy in time, last status: %s%s", container, status, lastHealthOutput(ctx, client, container))
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(hc.Interval):
+			}
+		}
 	}
 }
 
-func StartContainer(containerName string) txman.Callback {
+// lastHealthOutput returns the output of the last health check of container, formatted
+// to be appended to an error message, or nothing if there is no output.
+func lastHealthOutput(ctx context.Context, client sshexec.Service, container string) string {
+	var out bytes.Buffer
+	err := client.Run(ctx, command.ContainerHealthLog(container), sshexec.WithStdout(&out))
+	if err != nil {
+		return ""
+	}
+	var results []struct {
+		Output string `json:"Output"`
+	}
+	if err := json.Unmarshal(bytes.TrimSpace(out.Bytes()), &results); err != nil || len(results) == 0 {
+		return ""
+	}
+	output := strings.TrimSpace(results[len(results)-1].Output)
+	if output == "" {
+		return ""
+	}
+	return ": " + output
+}
+
+// runningRetries is how many times the state of a container is checked before giving up
+const runningRetries = 10
+
+// containerRunning is the state docker reports for running containers
+const containerRunning = "running"
+
+// WaitForRunning polls the state of container until docker reports it running.
+// Unlike WaitForHealthy, it does not depend on the configured health check.
+func WaitForRunning(container string) txman.Callback {
 	return func(ctx context.Context, client sshexec.Service) error {
-		return client.Run(ctx, command.StartContainer(containerName))
+		var state string
+		for attempt := range runningRetries {
+			if attempt > 0 {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(time.Second):
+				}
+			}
+			var out bytes.Buffer
+			err := client.Run(ctx, command.ContainerState(container), sshexec.WithStdout(&out))
+			if err != nil {
+				return fmt.Errorf("failed to inspect state of container %s: %w", container, err)
+			}
+			// no state is reported in dry-run mode
+			if state = strings.TrimSpace(out.String()); state == containerRunning || state == "" {
+				return nil
+			}
+		}
+		return fmt.Errorf("container %s is not running after %d attempts, last state: %s", container, runningRetries, state)
 	}
 }
 
-type RemoteFileContent struct {
-	host string
-	data []byte
-	err  error
+// WaitForProxy retries the health check of proxy until it passes.
+func WaitForProxy(proxy Proxy) txman.Callback {
+	return func(ctx context.Context, client sshexec.Service) error {
+		var err error
+		for attempt := range proxyHealthRetries {
+			if attempt > 0 {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(time.Second):
+				}
+			}
+			if err = client.Run(ctx, proxy.Health()); err == nil {
+				return nil
+			}
+		}
+		return fmt.Errorf("proxy failed health check after %d attempts: %w", proxyHealthRetries, err)
+	}
 }
 
-func ReadRemoteFile(path string, resultsCh chan<- RemoteFileContent) txman.Callback {
+// ReloadProxy makes proxy pick up changed routes if it does not do it on its own.
+func ReloadProxy(proxy Proxy) txman.Callback {
 	return func(ctx context.Context, client sshexec.Service) error {
-		data, err := client.ReadFile(path)
-		result := RemoteFileContent{
-			host: client.Host(),
-			data: data,
-			err:  err,
+		cmd := proxy.Reload()
+		if cmd == "" {
+			return nil
+		}
+		return client.Run(ctx, cmd)
+	}
+}
+
+// Pause waits for d or until ctx is done. It does not wait in dry-run mode.
+func Pause(d time.Duration) txman.Callback {
+	return func(ctx context.Context, _ sshexec.Service) error {
+		if cfg := config.Get(); cfg != nil && cfg.DryRun {
+			return nil
 		}
-		resultsCh <- result
-		if err != nil {
-			return fmt.Errorf("host %s: failed to read file %q: %w", client.Host(), path, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(d):
+			return nil
 		}
-		return nil
+	}
+}
+
+func StopContainer(containerName string) txman.Callback {
+	return func(ctx context.Context, client sshexec.Service) error {
+		return client.Run(ctx, command.StopContainer(containerName))
+	}
+}
+
+func RemoveStoppedContainer(containerName string) txman.Callback {
+	return func(ctx context.Context, client sshexec.Service) error {
+		return client.Run(ctx, command.RemoveStoppedContainer(containerName))
+	}
+}
+
+func StartContainer(containerName string) txman.Callback {
+	return func(ctx context.Context, client sshexec.Service) error {
+		return client.Run(ctx, command.StartContainer(containerName))
 	}
 }
 
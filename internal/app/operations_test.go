@@ -0,0 +1,56 @@
+package app
+
+import (
+	"context"
+	"testing"
+
+	"github.com/lex-unix/faino/internal/command"
+	"github.com/lex-unix/faino/internal/config"
+	"github.com/lex-unix/faino/internal/exec/sshexec/sshexectest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWaitForHealthy(t *testing.T) {
+	hc := config.Healthcheck{Port: 3000, Path: "/up", Status: 200, Retries: 1}
+
+	t.Run("reports the output of the failed check", func(t *testing.T) {
+		client := sshexectest.New("host1")
+		client.RunFunc = func(ctx context.Context, cmd string, stdin []byte) (string, error) {
+			if cmd == command.ContainerHealthLog("app-v2") {
+				return `[{"ExitCode":1,"Output":"starting"},{"ExitCode":1,"Output":"curl is missing in the image\n"}]`, nil
+			}
+			return "unhealthy\n", nil
+		}
+
+		err := WaitForHealthy("app-v2", hc)(context.Background(), client)
+		assert.EqualError(t, err, "container app-v2 is unhealthy: curl is missing in the image")
+	})
+
+	t.Run("fails without a health check if one is configured", func(t *testing.T) {
+		client := sshexectest.New("host1")
+		client.RunFunc = func(ctx context.Context, cmd string, stdin []byte) (string, error) {
+			return "none\n", nil
+		}
+
+		err := WaitForHealthy("app-v2", hc)(context.Background(), client)
+		assert.ErrorContains(t, err, "has no health check")
+		assert.NoError(t, WaitForHealthy("app-v2", config.Healthcheck{})(context.Background(), client))
+	})
+}
+
+func TestWaitForRunning(t *testing.T) {
+	client := sshexectest.New("host1")
+	states := []string{"restarting", "running"}
+	client.RunFunc = func(ctx context.Context, cmd string, stdin []byte) (string, error) {
+		state := states[0]
+		states = states[1:]
+		return state + "\n", nil
+	}
+
+	err := WaitForRunning("app-db")(context.Background(), client)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"docker inspect --format '{{.State.Status}}' app-db",
+		"docker inspect --format '{{.State.Status}}' app-db",
+	}, client.Cmds())
+}
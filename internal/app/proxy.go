@@ -0,0 +1,221 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lex-unix/faino/internal/command"
+	"github.com/lex-unix/faino/internal/config"
+	"github.com/lex-unix/faino/internal/exec/sshexec"
+	"github.com/lex-unix/faino/internal/logging"
+	"github.com/lex-unix/faino/internal/txman"
+)
+
+// Proxy is the reverse proxy that runs on every host and routes requests to app containers.
+// Implementations return remote commands instead of running them.
+type Proxy interface {
+	// Run returns the command that boots the proxy container with additional labels.
+	Run(labels ...string) string
+	// Reload returns the command that makes the proxy pick up changed routes,
+	// or an empty string if the proxy watches app containers on its own.
+	Reload() string
+	// Labels returns the labels of an app container that route requests to it.
+	Labels(service string, hc config.Healthcheck) []string
+	// Health returns the command that fails unless the proxy is ready to route requests.
+	Health() string
+	// Logs returns the command that prints the proxy logs.
+	Logs(follow bool, lines int, since string) string
+}
+
+// newProxy returns the proxy backend selected by proxy.kind.
+func newProxy(cfg *config.Config) Proxy {
+	switch cfg.Proxy.Kind {
+	case config.ProxyKindCaddy:
+		return &caddyProxy{cfg: cfg.Proxy, network: cfg.Container.Network}
+	default:
+		return &traefikProxy{cfg: cfg.Proxy, network: cfg.Container.Network}
+	}
+}
+
+// proxy returns the configured proxy backend.
+func (app *App) proxy() Proxy {
+	return newProxy(config.Get())
+}
+
+var routeNameRx = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// routeName returns the name the proxy knows the app by, so that several apps
+// can be routed by a single proxy.
+func routeName(service string) string {
+	return routeNameRx.ReplaceAllString(service, "-")
+}
+
+// upstreamPort returns the container port requests are routed to, or 0 if it is not known.
+func upstreamPort(r config.Routing, hc config.Healthcheck) int {
+	if r.Port != 0 {
+		return r.Port
+	}
+	return hc.Port
+}
+
+// labelFlags formats proxy container labels as `docker run` flags.
+func labelFlags(labels map[string]any, extra []string) []string {
+	var flags []string
+	if len(labels) > 0 {
+		flags = append(flags, formatFlags("label", labels))
+	}
+	for _, label := range extra {
+		flags = append(flags, "--label "+label)
+	}
+	return flags
+}
+
+// proxyConfigHashLabel is the proxy container label holding the hash of the config it was booted with
+const proxyConfigHashLabel = "faino.proxy.config-hash"
+
+// proxyConfigHash returns a hash of the effective proxy config, which changes
+// whenever the proxy container has to be recreated to apply the config.
+func proxyConfigHash(p Proxy) string {
+	sum := sha256.Sum256([]byte(p.Run()))
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+// runProxy returns the command that boots the proxy container labeled with its config hash.
+func runProxy(p Proxy) string {
+	return p.Run(fmt.Sprintf("%s=%s", proxyConfigHashLabel, proxyConfigHash(p)))
+}
+
+func envFlag(k, v string) string {
+	return fmt.Sprintf("--env %s=%s", k, v)
+}
+
+// ProxyDrift reports for every host whether the proxy container runs with a config
+// that differs from the current one and has to be rebooted to apply it.
+func (app *App) ProxyDrift(ctx context.Context) (map[string]bool, error) {
+	want := proxyConfigHash(app.proxy())
+
+	var mu sync.Mutex
+	drift := make(map[string]bool)
+	err := app.txmanager.Execute(ctx, func(ctx context.Context, client sshexec.Service) error {
+		got, err := app.proxyConfigHashOn(ctx, client)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		drift[client.Host()] = got != want
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return drift, nil
+}
+
+func (app *App) proxyConfigHashOn(ctx context.Context, client sshexec.Service) (string, error) {
+	var out bytes.Buffer
+	err := client.Run(ctx, command.ContainerLabel(config.Get().Proxy.Container, proxyConfigHashLabel), sshexec.WithStdout(&out))
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect proxy container on %s: %w", client.Host(), err)
+	}
+	return strings.TrimSpace(out.String()), nil
+}
+
+// warnProxyDrift logs a warning if the running proxy on the host does not match the current config.
+func (app *App) warnProxyDrift(ctx context.Context, client sshexec.Service) {
+	got, err := app.proxyConfigHashOn(ctx, client)
+	if err != nil {
+		logging.Warn(err.Error())
+		return
+	}
+	if got != proxyConfigHash(app.proxy()) {
+		logging.WarnHost(client.Host(), "proxy config changed, run `faino proxy reboot` to apply it")
+	}
+}
+
+// RebootProxy recreates the proxy container with the current config on every host that
+// runs proxied roles, in batches if rolling is set. If the new container fails to start
+// on a host, the previous container is restored.
+func (app *App) RebootProxy(ctx context.Context, rolling bool) error {
+	cfg := config.Get()
+	proxyApp, err := app.onHosts(proxiedHosts(appRoles(cfg)))
+	if err != nil {
+		return err
+	}
+	if proxyApp == nil {
+		return errors.New("the proxy does not run on any of the target hosts")
+	}
+	proxy := app.proxy()
+	container := cfg.Proxy.Container
+	backup := container + "-previous"
+
+	var opts []txman.Option
+	if rolling {
+		opts = rollingOptions(cfg.Deploy.Rolling)
+		if opts == nil {
+			opts = []txman.Option{txman.WithRolling(txman.Rolling{BatchSize: 1})}
+		}
+	}
+
+	rollback, err := proxyApp.txmanager.BeginTransaction(ctx, func(ctx context.Context, tx txman.Transaction) error {
+		// a backup left by an interrupted reboot would make the rename fail
+		err := tx.Run(ctx, command.RemoveContainer(backup), "")
+		if err != nil {
+			return err
+		}
+		err = tx.Run(ctx, command.BackupContainer(container, backup), command.RestoreContainer(container, backup))
+		if err != nil {
+			return err
+		}
+		err = tx.Run(ctx, runProxy(proxy), "")
+		if err != nil {
+			return err
+		}
+		if cfg.Container.Network != "" {
+			err = tx.Run(ctx, command.ConnectNetwork(cfg.Container.Network, container), "")
+			if err != nil {
+				return err
+			}
+		}
+		return tx.Do(ctx, WaitForProxy(proxy), nil)
+	}, opts...)
+
+	var partialErr *txman.PartialError
+	if errors.As(err, &partialErr) {
+		// failed hosts were restored, the rebooted ones no longer need their backup
+		proxyApp.removeProxyBackup(ctx, backup)
+		return fmt.Errorf("proxy rebooted only partially: %w", err)
+	}
+
+	if err != nil {
+		logging.Info("initiating rollback...")
+		rollbackCtx, rollbackCancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer rollbackCancel()
+		if rollbackErr := rollback(rollbackCtx); rollbackErr != nil {
+			return errors.Join(err, rollbackErr)
+		}
+		return fmt.Errorf("proxy reboot failed and was rolled back: %w", err)
+	}
+
+	proxyApp.removeProxyBackup(ctx, backup)
+	return nil
+}
+
+// removeProxyBackup removes the previous proxy container left by a reboot.
+// Failing to remove it is logged but not returned.
+func (app *App) removeProxyBackup(ctx context.Context, backup string) {
+	err := app.txmanager.Execute(ctx, func(ctx context.Context, client sshexec.Service) error {
+		return client.Run(ctx, command.RemoveContainer(backup))
+	})
+	if err != nil {
+		logging.Warnf("failed to remove previous proxy container %s: %s", backup, err)
+	}
+}
@@ -0,0 +1,139 @@
+package app
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/lex-unix/faino/internal/command"
+	"github.com/lex-unix/faino/internal/config"
+	"github.com/lex-unix/faino/internal/exec/localexec"
+	"github.com/lex-unix/faino/internal/exec/sshexec/sshexectest"
+	"github.com/lex-unix/faino/internal/txman"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTraefikLabels(t *testing.T) {
+	t.Run("defaults route every request", func(t *testing.T) {
+		p := &traefikProxy{cfg: config.Proxy{Routing: config.Routing{Entrypoints: []string{"web"}}}}
+		assert.Equal(t, []string{
+			"traefik.enable=true",
+			"traefik.http.routers.my-app.entrypoints=web",
+			"traefik.http.routers.my-app.rule=PathPrefix(`/`)",
+		}, p.Labels("my.app", config.Healthcheck{}))
+	})
+
+	t.Run("hosts, paths, middlewares and port", func(t *testing.T) {
+		p := &traefikProxy{cfg: config.Proxy{Routing: config.Routing{
+			Hosts:       []string{"example.com", "www.example.com"},
+			Paths:       []string{"/api"},
+			Entrypoints: []string{"web", "websecure"},
+			Middlewares: []string{"compress"},
+			Port:        8080,
+		}}}
+		assert.Equal(t, []string{
+			"traefik.enable=true",
+			"traefik.http.routers.api.entrypoints=web,websecure",
+			"traefik.http.routers.api.rule=(Host(`example.com`) || Host(`www.example.com`)) && PathPrefix(`/api`)",
+			"traefik.http.routers.api.middlewares=compress",
+			"traefik.http.routers.api.service=api",
+			"traefik.http.services.api.loadbalancer.server.port=8080",
+		}, p.Labels("api", config.Healthcheck{}))
+	})
+}
+
+func TestTraefikSSL(t *testing.T) {
+	ssl := config.SSL{Enabled: true, Challenge: config.ChallengeTLS, Volume: "faino-acme", CAServer: "https://pebble:14000/dir"}
+	p := &traefikProxy{cfg: config.Proxy{
+		Container: "traefik",
+		Img:       "traefik:v3.1",
+		Routing:   config.Routing{Hosts: []string{"example.com"}, Entrypoints: []string{"web"}},
+		SSL:       ssl,
+	}}
+
+	labels := p.Labels("api", config.Healthcheck{})
+	assert.Contains(t, labels, "traefik.http.routers.api.entrypoints=websecure")
+	assert.Contains(t, labels, "traefik.http.routers.api.tls.certresolver=faino")
+
+	args := p.sslArgs()
+	assert.Equal(t, ":443", args["entryPoints.websecure.address"])
+	assert.Equal(t, "websecure", args["entryPoints.web.http.redirections.entryPoint.to"])
+	assert.Equal(t, true, args["certificatesresolvers.faino.acme.tlschallenge"])
+	assert.Equal(t, "https://pebble:14000/dir", args["certificatesresolvers.faino.acme.caserver"])
+
+	run := p.Run()
+	assert.Contains(t, run, "-p 443:443 --volume faino-acme:/letsencrypt")
+	assert.Contains(t, run, "--name traefik")
+}
+
+func TestCaddyLabels(t *testing.T) {
+	p := &caddyProxy{cfg: config.Proxy{Routing: config.Routing{
+		Hosts: []string{"example.com"},
+		Paths: []string{"/api/"},
+		Port:  8080,
+	}}}
+	hc := config.Healthcheck{Port: 8080, Path: "/up"}
+
+	labels := p.Labels("api", hc)
+	assert.Equal(t, "caddy=http://example.com", labels[0])
+	assert.Contains(t, labels, "caddy.@api.path=/api*")
+	assert.Contains(t, labels, "caddy.reverse_proxy=@api {{upstreams 8080}}")
+	assert.Contains(t, labels, "caddy.reverse_proxy.health_uri=/up")
+
+	p.cfg.SSL.Enabled = true
+	assert.Equal(t, "caddy=example.com", p.Labels("api", hc)[0])
+}
+
+func TestNewProxy(t *testing.T) {
+	cfg := &config.Config{Proxy: config.Proxy{Kind: config.ProxyKindCaddy, Container: "caddy", Img: "caddy"}}
+	p := newProxy(cfg)
+	assert.IsType(t, &caddyProxy{}, p)
+	assert.True(t, strings.HasSuffix(p.Run(), "caddy docker-proxy"))
+
+	cfg.Proxy.Kind = config.ProxyKindTraefik
+	assert.IsType(t, &traefikProxy{}, newProxy(cfg))
+}
+
+func TestProxyConfigHash(t *testing.T) {
+	cfg := config.Proxy{
+		Container: "traefik",
+		Img:       "traefik:v3.1",
+		Args:      map[string]any{"log.level": "DEBUG", "api.dashboard": true},
+		Labels:    map[string]any{"a": 1, "b": 2},
+	}
+	p := &traefikProxy{cfg: cfg}
+	hash := proxyConfigHash(p)
+	assert.Equal(t, hash, proxyConfigHash(&traefikProxy{cfg: cfg}), "hash must be stable")
+	assert.Contains(t, runProxy(p), "--label faino.proxy.config-hash="+hash)
+
+	cfg.Img = "traefik:v3.2"
+	assert.NotEqual(t, hash, proxyConfigHash(&traefikProxy{cfg: cfg}))
+}
+
+func TestRebootProxy(t *testing.T) {
+	loadTestConfig(t, `
+service: app
+image: app
+servers: [host1, host2]
+registry:
+  username: user
+  password: password
+roles:
+  web:
+    hosts: [host1]
+  worker:
+    hosts: [host2]
+`)
+	web := sshexectest.New("host1")
+	worker := sshexectest.New("host2")
+	app := New(localexec.NewRecorder(nil), WithTxManager(txman.New(web, worker)))
+
+	err := app.RebootProxy(context.Background(), false)
+	assert.NoError(t, err)
+	assert.Empty(t, worker.Cmds(), "the proxy is not rebooted on hosts without proxied roles")
+	cmds := web.Cmds()
+	if assert.GreaterOrEqual(t, len(cmds), 2) {
+		assert.Equal(t, command.RemoveContainer("traefik-previous"), cmds[0], "a stale backup is removed first")
+		assert.Equal(t, command.BackupContainer("traefik", "traefik-previous"), cmds[1])
+	}
+}
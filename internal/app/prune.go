@@ -0,0 +1,187 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/lex-unix/faino/internal/command"
+	"github.com/lex-unix/faino/internal/config"
+	"github.com/lex-unix/faino/internal/exec/sshexec"
+	"github.com/lex-unix/faino/internal/logging"
+)
+
+// PruneOptions selects what Prune removes.
+type PruneOptions struct {
+	Containers bool
+	Images     bool
+}
+
+// PruneResult describes what was pruned on a host.
+type PruneResult struct {
+	Containers []string
+	Images     []string
+	// Freed is the disk space in bytes that the host filesystem gained.
+	Freed int64
+}
+
+// Prune removes stopped app containers and images of versions outside of the configured
+// retention window on every host while holding the deploy lock.
+func (app *App) Prune(ctx context.Context, opts PruneOptions) (map[string]PruneResult, error) {
+	var results map[string]PruneResult
+	err := app.withLock(ctx, "prune", func() error {
+		var err error
+		results, err = app.prune(ctx, opts)
+		return err
+	})
+	return results, err
+}
+
+func (app *App) prune(ctx context.Context, opts PruneOptions) (map[string]PruneResult, error) {
+	cfg := config.Get()
+
+	if err := app.loadConsistentHistory(ctx); err != nil {
+		return nil, fmt.Errorf("failed to read history at %s: %w", app.historyFilePath, err)
+	}
+	app.sortHistory()
+	var protected []string
+	if canary, ok := app.activeCanary(); ok {
+		protected = append(protected, canary.Version)
+	}
+
+	var containerVersions, imageVersions []string
+	if opts.Containers {
+		containerVersions = prunableVersions(app.history, cfg.Prune.RetainContainers, protected)
+	}
+	if opts.Images {
+		imageVersions = prunableVersions(app.history, cfg.Prune.RetainImages, protected)
+	}
+	results := make(map[string]PruneResult)
+	if len(containerVersions) == 0 && len(imageVersions) == 0 {
+		return results, nil
+	}
+
+	roles := appRoles(cfg)
+	var mu sync.Mutex
+	err := app.txmanager.Execute(ctx, func(ctx context.Context, client sshexec.Service) error {
+		before, err := diskAvailable(ctx, client)
+		if err != nil {
+			return err
+		}
+
+		var result PruneResult
+		var out bytes.Buffer
+		for _, version := range containerVersions {
+			for _, r := range rolesOn(roles, client.Host()) {
+				container := app.containerName(r, version)
+				out.Reset()
+				// running containers are never removed
+				err := client.Run(ctx, command.RemoveStoppedContainer(container), sshexec.WithStdout(&out))
+				if err != nil {
+					return err
+				}
+				if strings.TrimSpace(out.String()) != "" {
+					result.Containers = append(result.Containers, container)
+					err = client.Run(ctx, command.RemoveFile(envFilePath(container)))
+					if err != nil {
+						return err
+					}
+				}
+			}
+		}
+		for _, version := range imageVersions {
+			image := app.imageName(version)
+			out.Reset()
+			// images used by a container are never removed
+			err := client.Run(ctx, command.RemoveImage(image), sshexec.WithStdout(&out))
+			if err != nil {
+				return err
+			}
+			if strings.Contains(out.String(), "Untagged") {
+				result.Images = append(result.Images, image)
+			}
+		}
+
+		after, err := diskAvailable(ctx, client)
+		if err != nil {
+			return err
+		}
+		result.Freed = max(after-before, 0)
+
+		mu.Lock()
+		results[client.Host()] = result
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// autoPrune prunes containers and images of old versions after a deploy.
+// Failing to prune is logged but not returned.
+func (app *App) autoPrune(ctx context.Context) {
+	results, err := app.prune(ctx, PruneOptions{Containers: true, Images: true})
+	if err != nil {
+		logging.Warnf("failed to prune old versions: %s", err)
+		return
+	}
+	for host, result := range results {
+		logging.InfoHostf(host, "pruned %d containers and %d images", len(result.Containers), len(result.Images))
+	}
+}
+
+// prunableVersions returns the versions in history, which must be sorted from the most
+// recent entry, whose containers and images can be removed when retain versions are kept.
+// The retain most recently deployed versions, which rollbacks can switch to, and the
+// protected versions are never returned. Zero retain keeps every version.
+func prunableVersions(history []History, retain int, protected []string) []string {
+	if retain <= 0 {
+		return nil
+	}
+
+	keep := slices.Clone(protected)
+	kept := 0
+	for _, entry := range history {
+		if kept == retain {
+			break
+		}
+		if entry.Succeeded() && !slices.Contains(keep, entry.Version) {
+			keep = append(keep, entry.Version)
+			kept++
+		}
+	}
+
+	var versions []string
+	for _, entry := range history {
+		if !slices.Contains(keep, entry.Version) && !slices.Contains(versions, entry.Version) {
+			versions = append(versions, entry.Version)
+		}
+	}
+	return versions
+}
+
+// diskAvailable returns the bytes available for docker data on the host.
+// It returns zero if the host did not report anything, e.g. in dry-run mode.
+func diskAvailable(ctx context.Context, client sshexec.Service) (int64, error) {
+	var out bytes.Buffer
+	err := client.Run(ctx, command.DiskAvailable(), sshexec.WithStdout(&out))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read available disk space: %w", err)
+	}
+	s := strings.TrimSpace(out.String())
+	if s == "" {
+		return 0, nil
+	}
+	avail, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse available disk space %q: %w", s, err)
+	}
+	return avail, nil
+}
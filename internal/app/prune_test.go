@@ -0,0 +1,25 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrunableVersions(t *testing.T) {
+	// sorted from the most recent entry
+	history := []History{
+		{Version: "v6", Status: HistoryStatusCanary},
+		{Version: "v5", Status: HistoryStatusRolledBack},
+		{Version: "v2", Status: HistoryStatusSuccess},
+		{Version: "v4", Status: HistoryStatusSuccess},
+		{Version: "v3", Status: HistoryStatusSuccess},
+		{Version: "v2", Status: HistoryStatusSuccess},
+		{Version: "v1", Status: HistoryStatusSuccess},
+	}
+
+	assert.Equal(t, []string{"v5", "v3", "v1"}, prunableVersions(history, 2, []string{"v6"}))
+	assert.Equal(t, []string{"v6", "v5", "v4", "v3", "v1"}, prunableVersions(history, 1, nil))
+	assert.Equal(t, []string{"v5"}, prunableVersions(history, 10, []string{"v6"}))
+	assert.Nil(t, prunableVersions(history, 0, nil))
+}
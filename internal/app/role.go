@@ -0,0 +1,151 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+
+	"github.com/lex-unix/faino/internal/config"
+)
+
+// role is a group of hosts that run the app image with its own command and options.
+// Without roles configured, the app runs as a single unnamed role on every server.
+type role struct {
+	name      string
+	hosts     []string
+	cmd       string
+	env       map[string]string
+	proxied   bool
+	container config.Container
+}
+
+// appRoles returns the roles configured in cfg sorted by name.
+func appRoles(cfg *config.Config) []role {
+	if len(cfg.Roles) == 0 {
+		return []role{{hosts: cfg.Servers, proxied: true}}
+	}
+	roles := make([]role, 0, len(cfg.Roles))
+	for _, name := range slices.Sorted(maps.Keys(cfg.Roles)) {
+		r := cfg.Roles[name]
+		roles = append(roles, role{
+			name:      name,
+			hosts:     r.Hosts,
+			cmd:       r.Cmd,
+			env:       r.Env,
+			proxied:   r.Proxied(name),
+			container: r.Container,
+		})
+	}
+	return roles
+}
+
+// rolesOn returns the roles that run on host.
+func rolesOn(roles []role, host string) []role {
+	return slices.DeleteFunc(slices.Clone(roles), func(r role) bool {
+		return !slices.Contains(r.hosts, host)
+	})
+}
+
+// proxiedHosts returns the hosts that run at least one proxied role.
+func proxiedHosts(roles []role) []string {
+	var hosts []string
+	for _, r := range roles {
+		if r.proxied {
+			hosts = append(hosts, r.hosts...)
+		}
+	}
+	slices.Sort(hosts)
+	return slices.Compact(hosts)
+}
+
+// roleContainerName returns the name of the container running version of the app for the named role.
+// The role name is left out for the unnamed role.
+func roleContainerName(service, roleName, version string) string {
+	if roleName == "" {
+		return fmt.Sprintf("%s-%s", service, version)
+	}
+	return fmt.Sprintf("%s-%s-%s", service, roleName, version)
+}
+
+// roleEnv returns the env of the containers of role r. Role env overrides the global env.
+func roleEnv(global map[string]string, r role) map[string]string {
+	env := make(map[string]string, len(global)+len(r.env))
+	maps.Copy(env, global)
+	maps.Copy(env, r.env)
+	return env
+}
+
+// targetRoles returns the role selected with --role, or every role if none was selected.
+func (app *App) targetRoles() []role {
+	cfg := config.Get()
+	roles := appRoles(cfg)
+	if cfg.Role == "" {
+		return roles
+	}
+	return slices.DeleteFunc(roles, func(r role) bool { return r.name != cfg.Role })
+}
+
+// onHosts returns an App that manages only the target hosts that are also in hosts.
+// It returns nil if none of the target hosts are in hosts.
+func (app *App) onHosts(hosts []string) (*App, error) {
+	targets := slices.DeleteFunc(app.txmanager.Hosts(), func(host string) bool {
+		return !slices.Contains(hosts, host)
+	})
+	if len(targets) == 0 {
+		return nil, nil
+	}
+	txmanager, err := app.txmanager.Subset(targets...)
+	if err != nil {
+		return nil, err
+	}
+	sub := *app
+	sub.txmanager = txmanager
+	return &sub, nil
+}
+
+// forEachRole calls fn for every targeted role with an App that manages only the
+// target hosts of the role. Roles that do not run on any target host are skipped.
+// If concurrent is set, fn is called for all roles at once, e.g. to stream logs.
+func (app *App) forEachRole(ctx context.Context, concurrent bool, fn func(ctx context.Context, r role, roleApp *App) error) error {
+	type target struct {
+		role role
+		app  *App
+	}
+	var targets []target
+	for _, r := range app.targetRoles() {
+		roleApp, err := app.onHosts(r.hosts)
+		if err != nil {
+			return err
+		}
+		if roleApp != nil {
+			targets = append(targets, target{role: r, app: roleApp})
+		}
+	}
+	if len(targets) == 0 {
+		return errors.New("no role runs on the target hosts")
+	}
+
+	if !concurrent {
+		for _, t := range targets {
+			if err := fn(ctx, t.role, t.app); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(targets))
+	for i, t := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = fn(ctx, t.role, t.app)
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
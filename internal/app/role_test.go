@@ -0,0 +1,64 @@
+package app
+
+import (
+	"context"
+	"testing"
+
+	"github.com/lex-unix/faino/internal/command"
+	"github.com/lex-unix/faino/internal/config"
+	"github.com/lex-unix/faino/internal/exec/sshexec/sshexectest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAppRoles(t *testing.T) {
+	t.Run("without roles", func(t *testing.T) {
+		cfg := &config.Config{Servers: []string{"a", "b"}}
+		roles := appRoles(cfg)
+
+		assert.Equal(t, []role{{hosts: []string{"a", "b"}, proxied: true}}, roles)
+		assert.Equal(t, "app-v1", roleContainerName("app", roles[0].name, "v1"))
+	})
+
+	t.Run("with roles", func(t *testing.T) {
+		proxied := true
+		cfg := &config.Config{
+			Servers: []string{"a", "b"},
+			Roles: map[string]config.Role{
+				"worker": {Hosts: []string{"b"}, Cmd: "bin/jobs"},
+				"web":    {Hosts: []string{"a", "b"}},
+				"admin":  {Hosts: []string{"a"}, Proxy: &proxied},
+			},
+		}
+		roles := appRoles(cfg)
+
+		names := make([]string, 0, len(roles))
+		for _, r := range roles {
+			names = append(names, r.name)
+		}
+		assert.Equal(t, []string{"admin", "web", "worker"}, names)
+		assert.True(t, roles[0].proxied)
+		assert.True(t, roles[1].proxied)
+		assert.False(t, roles[2].proxied)
+		assert.Equal(t, "bin/jobs", roles[2].cmd)
+
+		assert.Len(t, rolesOn(roles, "a"), 2)
+		assert.Len(t, rolesOn(roles, "b"), 2)
+		assert.Equal(t, []string{"a", "b"}, proxiedHosts(roles))
+		assert.Equal(t, "app-worker-v1", roleContainerName("app", "worker", "v1"))
+	})
+}
+
+func TestWaitForRole(t *testing.T) {
+	// the image may have a health check, which is ignored for roles without the proxy
+	client := sshexectest.New("host1")
+	client.RunFunc = func(ctx context.Context, cmd string, stdin []byte) (string, error) {
+		if cmd == command.ContainerState("app-worker-v2") {
+			return "running\n", nil
+		}
+		return "starting\n", nil
+	}
+
+	err := waitForRole(role{name: "worker"})("app-worker-v2")(context.Background(), client)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{command.ContainerState("app-worker-v2")}, client.Cmds())
+}
@@ -0,0 +1,101 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/lex-unix/faino/internal/command"
+	"github.com/lex-unix/faino/internal/config"
+	"github.com/lex-unix/faino/internal/exec/sshexec"
+	"github.com/lex-unix/faino/internal/logging"
+	"github.com/lex-unix/faino/internal/stream"
+)
+
+// ExitError is returned when a one-off command exits with a non-zero status on a host.
+type ExitError struct {
+	Host string
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("command exited with status %d on %s", e.Code, e.Host)
+}
+
+// RunOptions configures a one-off command run by RunService.
+type RunOptions struct {
+	// Cmd is the command to run in the container.
+	Cmd string
+	// Version is the version of the app image to run, the latest deployed version if empty.
+	Version string
+	// Interactive attaches a terminal to the container.
+	Interactive bool
+}
+
+// RunService runs a one-off command on every host in a new container started from
+// the app image. The container gets the env and volumes of the app container and
+// is removed once the command exits.
+func (app *App) RunService(ctx context.Context, opts RunOptions) error {
+	if err := app.LoadHistory(ctx); err != nil {
+		return err
+	}
+	version := opts.Version
+	if version == "" {
+		version = app.LatestVersion()
+		if version == "" {
+			return errors.New("no version is deployed yet")
+		}
+	} else if !slices.ContainsFunc(app.history, func(h History) bool { return h.Version == version && h.Succeeded() }) {
+		return fmt.Errorf("version %s does not exist", version)
+	}
+
+	roles := app.targetRoles()
+	return app.txmanager.Execute(ctx, func(ctx context.Context, client sshexec.Service) error {
+		runCmd, ok := app.oneOffCmd(client.Host(), roles, version, opts.Cmd, nil, opts.Interactive)
+		if !ok {
+			return nil
+		}
+
+		var err error
+		if opts.Interactive {
+			err = client.Run(ctx, runCmd, sshexec.WithPty())
+		} else {
+			var lineHandler stream.LineHandler = func(line []byte) {
+				logging.InfoHost(client.Host(), string(line))
+			}
+			var streamErrHandler stream.StreamErrHandler = func(err error) {
+				logging.ErrorHostf(client.Host(), "stream: %s", err)
+			}
+			sw := stream.New(lineHandler, streamErrHandler)
+			defer sw.Close()
+			err = client.Run(ctx, runCmd, sshexec.WithStdout(sw))
+		}
+		if code, ok := sshexec.ExitStatus(err); ok {
+			return &ExitError{Host: client.Host(), Code: code}
+		}
+		return err
+	})
+}
+
+// oneOffCmd returns the command that runs cmd on host in a one-off container of version,
+// configured like the app container of the first of roles that runs on host. It uses the
+// env file of that container, env is added on top of it. It returns false if none of
+// roles runs on host.
+func (app *App) oneOffCmd(host string, roles []role, version, cmd string, env map[string]string, interactive bool) (string, bool) {
+	cfg := config.Get()
+	hostRoles := rolesOn(roles, host)
+	if len(hostRoles) == 0 {
+		return "", false
+	}
+	r := hostRoles[0]
+
+	container := fmt.Sprintf("%s-run-%s", cfg.Service, generateRandomString(8))
+	envs := []string{"--env-file " + envFilePath(app.containerName(r, version))}
+	for _, k := range slices.Sorted(maps.Keys(env)) {
+		envs = append(envs, fmt.Sprintf("--env %s=%q", k, env[k]))
+	}
+	options := append(runFlags(cfg.Container), runFlags(r.container)...)
+	return command.RunOnce(app.imageName(version), container, envs, options, cmd, interactive), true
+}
@@ -0,0 +1,131 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/lex-unix/faino/internal/command"
+	"github.com/lex-unix/faino/internal/config"
+	"github.com/lex-unix/faino/internal/exec/sshexec"
+	"github.com/lex-unix/faino/internal/logging"
+)
+
+// hostPlatform returns the platform of the docker daemon on every host, which images are
+// built for when they are not pushed to a registry. Hosts must all run the same platform,
+// as `docker load` takes images of a single platform only.
+func (app *App) hostPlatform(ctx context.Context) (string, error) {
+	var mu sync.Mutex
+	var platforms []string
+	err := app.txmanager.Execute(ctx, func(ctx context.Context, client sshexec.Service) error {
+		var out bytes.Buffer
+		err := client.Run(ctx, command.ServerPlatform(), sshexec.WithStdout(&out))
+		if err != nil {
+			return fmt.Errorf("failed to read docker platform: %w", err)
+		}
+		platform := strings.TrimSpace(out.String())
+		if platform == "" {
+			return nil
+		}
+		mu.Lock()
+		if !slices.Contains(platforms, platform) {
+			platforms = append(platforms, platform)
+		}
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	switch len(platforms) {
+	case 0:
+		// hosts did not report anything, e.g. in dry-run mode
+		return config.Get().Build.Platform, nil
+	case 1:
+		return platforms[0], nil
+	default:
+		slices.Sort(platforms)
+		return "", fmt.Errorf("hosts run different platforms (%s), which requires registry.mode push", strings.Join(platforms, ", "))
+	}
+}
+
+// shipImage saves image from the local image store to a compressed archive
+// and loads it on every host in parallel by streaming the archive over ssh.
+func (app *App) shipImage(ctx context.Context, image string) error {
+	archive, err := os.CreateTemp("", "faino-image-*.tar.gz")
+	if err != nil {
+		return err
+	}
+	archive.Close()
+	defer os.Remove(archive.Name())
+
+	logging.Infof("saving image %s", image)
+	if err := app.lexec.Run(ctx, command.SaveImage(image, archive.Name())); err != nil {
+		return fmt.Errorf("failed to save image %s: %w", image, err)
+	}
+	info, err := os.Stat(archive.Name())
+	if err != nil {
+		return err
+	}
+
+	return app.txmanager.Execute(ctx, func(ctx context.Context, client sshexec.Service) error {
+		f, err := os.Open(archive.Name())
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		logging.InfoHostf(client.Host(), "loading image %s (%s)", image, formatSize(info.Size()))
+		r := &progressReader{r: f, host: client.Host(), total: info.Size()}
+		if err := client.Run(ctx, command.LoadImage(), sshexec.WithStdin(r)); err != nil {
+			return fmt.Errorf("failed to load image %s on %s: %w", image, client.Host(), err)
+		}
+		return nil
+	})
+}
+
+// progressStep is the share of an archive, in percent, between progress reports
+const progressStep = 25
+
+// progressReader logs how much of an image archive was sent to a host.
+type progressReader struct {
+	r     io.Reader
+	host  string
+	total int64
+	read  int64
+	// reported is the last reported percentage
+	reported int64
+}
+
+func (pr *progressReader) Read(p []byte) (int, error) {
+	n, err := pr.r.Read(p)
+	pr.read += int64(n)
+	if pr.total > 0 {
+		percent := pr.read * 100 / pr.total
+		if percent >= pr.reported+progressStep {
+			pr.reported = percent - percent%progressStep
+			logging.InfoHostf(pr.host, "sent %d%% of image", pr.reported)
+		}
+	}
+	return n, err
+}
+
+// formatSize formats size in bytes with a binary unit, e.g. 1.5 MiB.
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,30 @@
+package app
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProgressReader(t *testing.T) {
+	data := strings.Repeat("x", 100)
+	pr := &progressReader{r: strings.NewReader(data), host: "host", total: int64(len(data))}
+
+	buf := make([]byte, 30)
+	_, err := pr.Read(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(25), pr.reported)
+
+	n, err := io.Copy(io.Discard, pr)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(70), n)
+	assert.Equal(t, int64(100), pr.reported)
+}
+
+func TestFormatSize(t *testing.T) {
+	assert.Equal(t, "512 B", formatSize(512))
+	assert.Equal(t, "1.5 KiB", formatSize(1536))
+	assert.Equal(t, "2.0 GiB", formatSize(2<<30))
+}
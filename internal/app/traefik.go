@@ -0,0 +1,199 @@
+package app
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/lex-unix/faino/internal/command"
+	"github.com/lex-unix/faino/internal/config"
+)
+
+const (
+	httpEntrypoint  = "web"
+	httpsEntrypoint = "websecure"
+	certResolver    = "faino"
+
+	// acmeDir is where Traefik stores ACME certificates inside its container
+	acmeDir = "/letsencrypt"
+	// caCertificatesPath is where custom ACME CA certificates are mounted inside the proxy container
+	caCertificatesPath = "/etc/faino/acme-ca.pem"
+)
+
+// traefikProxy routes requests with Traefik, configured through app container labels.
+type traefikProxy struct {
+	cfg     config.Proxy
+	network string
+}
+
+func (p *traefikProxy) Run(labels ...string) string {
+	options := []string{"-p 80:80"}
+	options = append(options, p.sslFlags()...)
+	options = append(options, labelFlags(p.cfg.Labels, labels)...)
+
+	// explicitly configured arguments take precedence over generated ones
+	args := map[string]any{
+		"providers.docker":                         true,
+		"entryPoints." + httpEntrypoint + ".address": ":80",
+		"accesslog":                                true,
+		"ping":                                     true,
+	}
+	maps.Copy(args, p.sslArgs())
+	maps.Copy(args, p.cfg.Args)
+
+	return command.RunProxy(p.cfg.Img, p.cfg.Container, options, formatArgs(args))
+}
+
+// Reload returns an empty string, Traefik watches docker for app container changes.
+func (p *traefikProxy) Reload() string {
+	return ""
+}
+
+// Labels returns labels that route requests to the app container. If the container
+// port is known, app containers are grouped under a single proxy service with active
+// health checks, so old and new containers can serve traffic side by side while they are swapped.
+func (p *traefikProxy) Labels(service string, hc config.Healthcheck) []string {
+	r := p.cfg.Routing
+	name := routeName(service)
+	router := "traefik.http.routers." + name
+	lb := "traefik.http.services." + name + ".loadbalancer"
+
+	entrypoints := r.Entrypoints
+	if p.cfg.SSL.Enabled {
+		// plain HTTP is redirected to HTTPS, so the router serves the secure entrypoint instead
+		entrypoints = make([]string, 0, len(r.Entrypoints))
+		for _, ep := range r.Entrypoints {
+			if ep == httpEntrypoint {
+				ep = httpsEntrypoint
+			}
+			if !slices.Contains(entrypoints, ep) {
+				entrypoints = append(entrypoints, ep)
+			}
+		}
+	}
+
+	labels := []string{
+		"traefik.enable=true",
+		fmt.Sprintf("%s.entrypoints=%s", router, strings.Join(entrypoints, ",")),
+		fmt.Sprintf("%s.rule=%s", router, routingRule(r)),
+	}
+	if p.cfg.SSL.Enabled {
+		labels = append(labels,
+			fmt.Sprintf("%s.tls=true", router),
+			fmt.Sprintf("%s.tls.certresolver=%s", router, certResolver),
+		)
+	}
+	if len(r.Middlewares) > 0 {
+		labels = append(labels, fmt.Sprintf("%s.middlewares=%s", router, strings.Join(r.Middlewares, ",")))
+	}
+	if p.network != "" {
+		// the proxy must reach the container on the custom network
+		labels = append(labels, "traefik.docker.network="+p.network)
+	}
+
+	port := upstreamPort(r, hc)
+	if port == 0 {
+		return labels
+	}
+	labels = append(labels,
+		fmt.Sprintf("%s.service=%s", router, name),
+		fmt.Sprintf("%s.server.port=%d", lb, port),
+	)
+	if hc.Port == 0 {
+		return labels
+	}
+	return append(labels,
+		fmt.Sprintf("%s.healthcheck.port=%d", lb, hc.Port),
+		fmt.Sprintf("%s.healthcheck.path=%s", lb, hc.Path),
+		fmt.Sprintf("%s.healthcheck.interval=%s", lb, hc.Interval),
+		fmt.Sprintf("%s.healthcheck.timeout=%s", lb, hc.Timeout),
+	)
+}
+
+func (p *traefikProxy) Health() string {
+	return command.Exec(p.cfg.Container, "traefik healthcheck --ping", false)
+}
+
+func (p *traefikProxy) Logs(follow bool, lines int, since string) string {
+	return command.ContainerLogs(p.cfg.Container, follow, lines, since)
+}
+
+// sslFlags returns `docker run` flags that publish the HTTPS port and persist
+// certificates on the host.
+func (p *traefikProxy) sslFlags() []string {
+	ssl := p.cfg.SSL
+	if !ssl.Enabled {
+		return nil
+	}
+	flags := []string{
+		"-p 443:443",
+		fmt.Sprintf("--volume %s:%s", ssl.Volume, acmeDir),
+	}
+	if ssl.CACertificates != "" {
+		flags = append(flags,
+			fmt.Sprintf("--volume %s:%s:ro", ssl.CACertificates, caCertificatesPath),
+			envFlag("LEGO_CA_CERTIFICATES", caCertificatesPath),
+		)
+	}
+	return flags
+}
+
+// sslArgs returns Traefik arguments that add the HTTPS entrypoint, redirect HTTP
+// to it and configure the ACME certificate resolver used by app routers.
+func (p *traefikProxy) sslArgs() map[string]any {
+	ssl := p.cfg.SSL
+	if !ssl.Enabled {
+		return nil
+	}
+	resolver := "certificatesresolvers." + certResolver + ".acme"
+	args := map[string]any{
+		"entryPoints." + httpsEntrypoint + ".address":                            ":443",
+		"entryPoints." + httpEntrypoint + ".http.redirections.entryPoint.to":     httpsEntrypoint,
+		"entryPoints." + httpEntrypoint + ".http.redirections.entryPoint.scheme": "https",
+		resolver + ".storage": acmeDir + "/acme.json",
+	}
+	if ssl.Email != "" {
+		args[resolver+".email"] = ssl.Email
+	}
+	if ssl.CAServer != "" {
+		args[resolver+".caserver"] = ssl.CAServer
+	}
+	if ssl.Challenge == config.ChallengeTLS {
+		args[resolver+".tlschallenge"] = true
+	} else {
+		args[resolver+".httpchallenge.entrypoint"] = httpEntrypoint
+	}
+	return args
+}
+
+// routingRule returns the router rule matching any of the hosts and any of the path prefixes.
+// Without hosts and paths it matches every request.
+func routingRule(r config.Routing) string {
+	var rules []string
+	if len(r.Hosts) > 0 {
+		hosts := make([]string, len(r.Hosts))
+		for i, host := range r.Hosts {
+			hosts[i] = fmt.Sprintf("Host(`%s`)", host)
+		}
+		rules = append(rules, orRule(hosts))
+	}
+	if len(r.Paths) > 0 {
+		paths := make([]string, len(r.Paths))
+		for i, path := range r.Paths {
+			paths[i] = fmt.Sprintf("PathPrefix(`%s`)", path)
+		}
+		rules = append(rules, orRule(paths))
+	}
+	if len(rules) == 0 {
+		return "PathPrefix(`/`)"
+	}
+	return strings.Join(rules, " && ")
+}
+
+func orRule(rules []string) string {
+	if len(rules) == 1 {
+		return rules[0]
+	}
+	return "(" + strings.Join(rules, " || ") + ")"
+}
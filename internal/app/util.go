@@ -2,7 +2,13 @@ package app
 
 import (
 	"fmt"
+	"maps"
+	"slices"
 	"strings"
+
+	"github.com/lex-unix/faino/internal/command"
+	"github.com/lex-unix/faino/internal/config"
+	"github.com/lex-unix/faino/internal/txman"
 )
 
 func formatArg(k string, v any) string {
@@ -11,8 +17,8 @@ func formatArg(k string, v any) string {
 
 func formatArgs(argmap map[string]any) string {
 	args := make([]string, 0, len(argmap))
-	for k, v := range argmap {
-		args = append(args, formatArg(k, v))
+	for _, k := range slices.Sorted(maps.Keys(argmap)) {
+		args = append(args, formatArg(k, argmap[k]))
 	}
 	return strings.Join(args, " ")
 }
@@ -23,8 +29,97 @@ func formatFlag(f, k string, v any) string {
 
 func formatFlags(f string, flagmap map[string]any) string {
 	flags := make([]string, 0, len(flagmap))
-	for k, v := range flagmap {
-		flags = append(flags, formatFlag(f, k, v))
+	for _, k := range slices.Sorted(maps.Keys(flagmap)) {
+		flags = append(flags, formatFlag(f, k, flagmap[k]))
 	}
 	return strings.Join(flags, " ")
 }
+
+// healthFlags returns `docker run` flags for the configured health check of the app container.
+func healthFlags(hc config.Healthcheck) []string {
+	if !hc.Enabled() {
+		return nil
+	}
+	cmd := hc.Command
+	if cmd == "" {
+		cmd = command.HTTPHealthCmd(hc.Port, hc.Path, hc.Status, hc.Timeout)
+	}
+	return command.HealthFlags(cmd, hc.Interval, hc.Timeout, hc.Retries, hc.StartPeriod)
+}
+
+// containerFlags returns `docker run` flags for the configured runtime options of the app container.
+func containerFlags(c config.Container) []string {
+	var flags []string
+	for _, port := range c.Ports {
+		flags = append(flags, "--publish "+port)
+	}
+	for _, volume := range c.Volumes {
+		flags = append(flags, "--volume "+volume)
+	}
+	if c.Network != "" {
+		flags = append(flags, "--network "+c.Network)
+	}
+	if c.Memory != "" {
+		flags = append(flags, "--memory "+c.Memory)
+	}
+	if c.CPUs != "" {
+		flags = append(flags, "--cpus "+c.CPUs)
+	}
+	if c.Restart != "" {
+		flags = append(flags, "--restart "+c.Restart)
+	}
+	if c.User != "" {
+		flags = append(flags, "--user "+c.User)
+	}
+	for _, host := range c.ExtraHosts {
+		flags = append(flags, "--add-host "+host)
+	}
+	if c.Logging.Driver != "" {
+		flags = append(flags, "--log-driver "+c.Logging.Driver)
+	}
+	for _, k := range slices.Sorted(maps.Keys(c.Logging.Options)) {
+		flags = append(flags, fmt.Sprintf("--log-opt %s=%s", k, c.Logging.Options[k]))
+	}
+	for _, k := range slices.Sorted(maps.Keys(c.Options)) {
+		flags = append(flags, formatArg(k, c.Options[k]))
+	}
+	return flags
+}
+
+// runFlags returns `docker run` flags for the runtime options of c that one-off
+// containers share with the app container. Ports, restart policy and resource
+// limits are left out so that they do not clash with the running app.
+func runFlags(c config.Container) []string {
+	var flags []string
+	for _, volume := range c.Volumes {
+		flags = append(flags, "--volume "+volume)
+	}
+	if c.Network != "" {
+		flags = append(flags, "--network "+c.Network)
+	}
+	if c.User != "" {
+		flags = append(flags, "--user "+c.User)
+	}
+	for _, host := range c.ExtraHosts {
+		flags = append(flags, "--add-host "+host)
+	}
+	return flags
+}
+
+// rollingOptions returns transaction options for rolling updates if they are configured.
+func rollingOptions(r config.Rolling) []txman.Option {
+	if !r.Enabled() {
+		return nil
+	}
+	pause := r.Pause
+	// nothing settles between batches in dry-run mode
+	if cfg := config.Get(); cfg != nil && cfg.DryRun {
+		pause = 0
+	}
+	return []txman.Option{txman.WithRolling(txman.Rolling{
+		BatchSize:    r.BatchSize,
+		BatchPercent: r.BatchPercent,
+		Pause:        pause,
+		MaxFailures:  r.MaxFailures,
+	})}
+}
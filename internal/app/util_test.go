@@ -4,6 +4,7 @@ import (
 	"regexp"
 	"testing"
 
+	"github.com/lex-unix/faino/internal/config"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -29,3 +30,57 @@ func TestFormatFlags(t *testing.T) {
 	result := formatFlags("flag", flags)
 	assert.Regexp(t, regexp.MustCompile(`--flag arg\d=val\d --flag arg\d=val\d --flag arg\d=val\d`), result)
 }
+
+func TestContainerFlags(t *testing.T) {
+	c := config.Container{
+		Ports:      []string{"8080:80"},
+		Volumes:    []string{"data:/var/lib/app"},
+		Network:    "backend",
+		Memory:     "512m",
+		CPUs:       "0.5",
+		Restart:    "unless-stopped",
+		User:       "1000:1000",
+		ExtraHosts: []string{"db:10.0.0.2"},
+		Logging: config.Logging{
+			Driver:  "json-file",
+			Options: map[string]string{"max-size": "10m", "max-file": "3"},
+		},
+		Options: map[string]any{"init": true},
+	}
+
+	expected := []string{
+		"--publish 8080:80",
+		"--volume data:/var/lib/app",
+		"--network backend",
+		"--memory 512m",
+		"--cpus 0.5",
+		"--restart unless-stopped",
+		"--user 1000:1000",
+		"--add-host db:10.0.0.2",
+		"--log-driver json-file",
+		"--log-opt max-file=3",
+		"--log-opt max-size=10m",
+		"--init=true",
+	}
+	assert.Equal(t, expected, containerFlags(c))
+	assert.Empty(t, containerFlags(config.Container{}))
+}
+
+func TestRunFlags(t *testing.T) {
+	c := config.Container{
+		Ports:      []string{"8080:80"},
+		Volumes:    []string{"data:/var/lib/app"},
+		Network:    "backend",
+		Restart:    "unless-stopped",
+		User:       "1000:1000",
+		ExtraHosts: []string{"db:10.0.0.2"},
+	}
+
+	expected := []string{
+		"--volume data:/var/lib/app",
+		"--network backend",
+		"--user 1000:1000",
+		"--add-host db:10.0.0.2",
+	}
+	assert.Equal(t, expected, runFlags(c))
+}
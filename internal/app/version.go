@@ -0,0 +1,132 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/lex-unix/faino/internal/command"
+	"github.com/lex-unix/faino/internal/exec/localexec"
+	"github.com/lex-unix/faino/internal/validator"
+)
+
+// dirtySuffix is appended to versions built from a working tree with uncommitted changes.
+const dirtySuffix = "dirty"
+
+var (
+	ErrDirtyWorkingTree = errors.New("working tree has uncommitted changes, commit them or deploy with --allow-dirty")
+
+	// versionRx matches valid docker image tags
+	versionRx = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]{0,127}$`)
+)
+
+type DeployOptions struct {
+	// Version overrides the version derived from the git commit hash.
+	Version string
+	// AllowDirty allows deploying a working tree with uncommitted changes.
+	AllowDirty bool
+	// Canary limits the deploy to these hosts and records it as a canary
+	// that must be promoted or aborted.
+	Canary []string
+	// PromoteAfter promotes the canary automatically once it stayed healthy for this long.
+	// Zero leaves promotion to `faino deploy promote`.
+	PromoteAfter time.Duration
+}
+
+// release describes the code being deployed.
+type release struct {
+	Version string
+	Commit  string
+	Branch  string
+	Message string
+	Author  string
+}
+
+// newRelease collects git metadata for a new release. Unless overridden,
+// the version is the short hash of the HEAD commit.
+func (app *App) newRelease(ctx context.Context, opts DeployOptions) (release, error) {
+	r := release{Author: app.performer(ctx)}
+
+	if opts.Version != "" && !validator.Matches(opts.Version, versionRx) {
+		return r, fmt.Errorf("invalid version %q: must be a valid image tag", opts.Version)
+	}
+
+	hash, err := app.git(ctx, command.CommitHash())
+	if err != nil {
+		if opts.Version != "" {
+			r.Version = opts.Version
+			return r, nil
+		}
+		return r, fmt.Errorf("failed to get commit hash: %w", err)
+	}
+
+	if r.Commit, err = app.git(ctx, command.FullCommitHash()); err != nil {
+		return r, fmt.Errorf("failed to get commit hash: %w", err)
+	}
+	if r.Branch, err = app.git(ctx, command.CurrentBranch()); err != nil {
+		return r, fmt.Errorf("failed to get current branch: %w", err)
+	}
+	if r.Message, err = app.git(ctx, command.CommitMessage()); err != nil {
+		return r, fmt.Errorf("failed to get commit message: %w", err)
+	}
+
+	if opts.Version != "" {
+		r.Version = opts.Version
+		return r, nil
+	}
+
+	status, err := app.git(ctx, command.WorkingTreeStatus())
+	if err != nil {
+		return r, fmt.Errorf("failed to get working tree status: %w", err)
+	}
+	if status != "" {
+		if !opts.AllowDirty {
+			return r, ErrDirtyWorkingTree
+		}
+		diff, err := app.git(ctx, command.WorkingTreeDiff())
+		if err != nil {
+			return r, fmt.Errorf("failed to get working tree diff: %w", err)
+		}
+		untracked, err := app.git(ctx, command.UntrackedFilesHash())
+		if err != nil {
+			return r, fmt.Errorf("failed to hash untracked files: %w", err)
+		}
+		// uncommitted changes can differ between deploys of the same commit
+		hash = fmt.Sprintf("%s-%s-%s", hash, dirtySuffix, dirtyHash(status, diff, untracked))
+	}
+	r.Version = hash
+
+	return r, nil
+}
+
+// dirtyHash returns a short hash identifying uncommitted changes, so that the same changes
+// on top of a commit always get the same version. The diff leaves out untracked files,
+// which are covered by their names in status and the hashes of their contents.
+func dirtyHash(status, diff, untracked string) string {
+	sum := sha256.Sum256([]byte(status + "\n" + diff + "\n" + untracked))
+	return hex.EncodeToString(sum[:])[:6]
+}
+
+// performer returns the name of the person running faino, preferring the git user name.
+func (app *App) performer(ctx context.Context) string {
+	if name, err := app.git(ctx, command.GitUserName()); err == nil && name != "" {
+		return name
+	}
+	return os.Getenv("USER")
+}
+
+// git runs a local git command and returns its trimmed output.
+func (app *App) git(ctx context.Context, cmd string) (string, error) {
+	var out bytes.Buffer
+	if err := app.lexec.Run(ctx, cmd, localexec.WithStdout(&out)); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out.String()), nil
+}
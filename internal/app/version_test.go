@@ -0,0 +1,20 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDirtyHash(t *testing.T) {
+	status := " M main.go\n?? new.go"
+	diff := "diff --git a/main.go b/main.go"
+	untracked := "b6fc4c620b67d95f953a5c1c1230aaab5db5a1b0"
+
+	assert.Len(t, dirtyHash(status, diff, untracked), 6)
+	assert.Equal(t, dirtyHash(status, diff, untracked), dirtyHash(status, diff, untracked))
+	assert.NotEqual(t, dirtyHash(status, diff, untracked), dirtyHash(status, diff+"\n+changed", untracked))
+	assert.NotEqual(t, dirtyHash(status, diff, untracked), dirtyHash(status+"\n?? other.go", diff, untracked))
+	// editing an untracked file changes neither the status nor the diff
+	assert.NotEqual(t, dirtyHash(status, diff, untracked), dirtyHash(status, diff, "9daeafb9864cf43055ae93beb0afd6c7d144bfa4"))
+}
@@ -0,0 +1,34 @@
+package accessory
+
+import (
+	"context"
+
+	"github.com/spf13/cobra"
+	bootCmd "github.com/lex-unix/faino/internal/cli/accessory/boot"
+	detailsCmd "github.com/lex-unix/faino/internal/cli/accessory/details"
+	execCmd "github.com/lex-unix/faino/internal/cli/accessory/exec"
+	logsCmd "github.com/lex-unix/faino/internal/cli/accessory/logs"
+	rebootCmd "github.com/lex-unix/faino/internal/cli/accessory/reboot"
+	removeCmd "github.com/lex-unix/faino/internal/cli/accessory/remove"
+	startCmd "github.com/lex-unix/faino/internal/cli/accessory/start"
+	stopCmd "github.com/lex-unix/faino/internal/cli/accessory/stop"
+	"github.com/lex-unix/faino/internal/cli/cliutil"
+)
+
+func NewCmdAccessory(ctx context.Context, f *cliutil.Factory) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "accessory",
+		Short: "Manage accessories on servers",
+	}
+
+	cmd.AddCommand(bootCmd.NewCmdBoot(ctx, f))
+	cmd.AddCommand(rebootCmd.NewCmdReboot(ctx, f))
+	cmd.AddCommand(startCmd.NewCmdStart(ctx, f))
+	cmd.AddCommand(stopCmd.NewCmdStop(ctx, f))
+	cmd.AddCommand(removeCmd.NewCmdRemove(ctx, f))
+	cmd.AddCommand(logsCmd.NewCmdLogs(ctx, f))
+	cmd.AddCommand(execCmd.NewCmdExec(ctx, f))
+	cmd.AddCommand(detailsCmd.NewCmdDetails(ctx, f))
+
+	return cmd
+}
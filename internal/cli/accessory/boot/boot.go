@@ -0,0 +1,32 @@
+package boot
+
+import (
+	"context"
+
+	"github.com/spf13/cobra"
+	"github.com/lex-unix/faino/internal/cli/cliutil"
+	"github.com/lex-unix/faino/internal/logging"
+)
+
+func NewCmdBoot(ctx context.Context, f *cliutil.Factory) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "boot <name>",
+		Short: "Boot accessory on its servers",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			app, err := f.App()
+			if err != nil {
+				return err
+			}
+
+			if err := app.BootAccessory(ctx, args[0]); err != nil {
+				return err
+			}
+
+			logging.Info("accessory booted on servers")
+			return nil
+		},
+	}
+
+	return cmd
+}
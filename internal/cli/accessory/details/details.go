@@ -0,0 +1,36 @@
+package details
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/lex-unix/faino/internal/cli/cliutil"
+)
+
+func NewCmdDetails(ctx context.Context, f *cliutil.Factory) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "details <name>",
+		Short: "Show accessory container on its servers",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			app, err := f.App()
+			if err != nil {
+				return err
+			}
+
+			info, err := app.ShowAccessoryInfo(ctx, args[0])
+			if err != nil {
+				return err
+			}
+
+			for host, output := range info {
+				fmt.Printf("Host %s:\n%s\n", host, output)
+			}
+
+			return nil
+		},
+	}
+
+	return cmd
+}
@@ -0,0 +1,47 @@
+package exec
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/lex-unix/faino/internal/cli/cliutil"
+)
+
+type ExecOptions struct {
+	interactive bool
+}
+
+func NewCmdExec(ctx context.Context, f *cliutil.Factory) *cobra.Command {
+	opts := ExecOptions{
+		interactive: false,
+	}
+	cmd := &cobra.Command{
+		Use:   "exec <name> <cmd>",
+		Short: "Execute a custom command on servers within the accessory container",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := f.Config()
+			if err != nil {
+				return err
+			}
+			if opts.interactive && cfg.Host == "" {
+				return fmt.Errorf("--interactive must be used with --host flag")
+			}
+			app, err := f.App()
+			if err != nil {
+				return err
+			}
+
+			if err := app.ExecAccessory(ctx, args[0], args[1], opts.interactive); err != nil {
+				return err
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVarP(&opts.interactive, "interactive", "i", false, "Start interactive session on container")
+
+	return cmd
+}
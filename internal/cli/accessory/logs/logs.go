@@ -0,0 +1,40 @@
+package logs
+
+import (
+	"context"
+
+	"github.com/spf13/cobra"
+	"github.com/lex-unix/faino/internal/cli/cliutil"
+)
+
+type LogsOptions struct {
+	Follow bool
+	Lines  int
+	Since  string
+}
+
+func NewCmdLogs(ctx context.Context, f *cliutil.Factory) *cobra.Command {
+	opts := LogsOptions{}
+	cmd := &cobra.Command{
+		Use:   "logs <name>",
+		Short: "Fetch logs from accessory container on its servers",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			app, err := f.App()
+			if err != nil {
+				return err
+			}
+
+			if err := app.AccessoryLogs(ctx, args[0], opts.Follow, opts.Lines, opts.Since); err != nil {
+				return err
+			}
+			return nil
+		},
+	}
+
+	cmd.PersistentFlags().BoolVarP(&opts.Follow, "follow", "f", false, "Follow logs on servers")
+	cmd.PersistentFlags().IntVarP(&opts.Lines, "lines", "n", 100, "Number of lines to show from each server")
+	cmd.PersistentFlags().StringVar(&opts.Since, "since", "", "Show lines since timestamp (e.g. 2013-01-02T13:23:37Z) or relative (e.g. 42m for 42 minutes)")
+
+	return cmd
+}
@@ -0,0 +1,32 @@
+package reboot
+
+import (
+	"context"
+
+	"github.com/spf13/cobra"
+	"github.com/lex-unix/faino/internal/cli/cliutil"
+	"github.com/lex-unix/faino/internal/logging"
+)
+
+func NewCmdReboot(ctx context.Context, f *cliutil.Factory) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "reboot <name>",
+		Short: "Recreate accessory container on its servers to apply config changes",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			app, err := f.App()
+			if err != nil {
+				return err
+			}
+
+			if err := app.RebootAccessory(ctx, args[0]); err != nil {
+				return err
+			}
+
+			logging.Info("accessory rebooted on servers")
+			return nil
+		},
+	}
+
+	return cmd
+}
@@ -0,0 +1,32 @@
+package remove
+
+import (
+	"context"
+
+	"github.com/spf13/cobra"
+	"github.com/lex-unix/faino/internal/cli/cliutil"
+	"github.com/lex-unix/faino/internal/logging"
+)
+
+func NewCmdRemove(ctx context.Context, f *cliutil.Factory) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "remove <name>",
+		Short: "Remove accessory container and files from its servers, keeping volumes",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			app, err := f.App()
+			if err != nil {
+				return err
+			}
+
+			if err := app.RemoveAccessory(ctx, args[0]); err != nil {
+				return err
+			}
+
+			logging.Info("accessory removed from servers")
+			return nil
+		},
+	}
+
+	return cmd
+}
@@ -0,0 +1,32 @@
+package start
+
+import (
+	"context"
+
+	"github.com/spf13/cobra"
+	"github.com/lex-unix/faino/internal/cli/cliutil"
+	"github.com/lex-unix/faino/internal/logging"
+)
+
+func NewCmdStart(ctx context.Context, f *cliutil.Factory) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "start <name>",
+		Short: "Start existing accessory container on its servers",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			app, err := f.App()
+			if err != nil {
+				return err
+			}
+
+			if err := app.StartAccessory(ctx, args[0]); err != nil {
+				return err
+			}
+
+			logging.Info("accessory container started on servers")
+			return nil
+		},
+	}
+
+	return cmd
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/spf13/cobra"
 	execCmd "github.com/lex-unix/faino/internal/cli/app/exec"
 	restartCmd "github.com/lex-unix/faino/internal/cli/app/restart"
+	runCmd "github.com/lex-unix/faino/internal/cli/app/run"
 	showCmd "github.com/lex-unix/faino/internal/cli/app/show"
 	startCmd "github.com/lex-unix/faino/internal/cli/app/start"
 	stopCmd "github.com/lex-unix/faino/internal/cli/app/stop"
@@ -18,11 +19,14 @@ func NewCmdApp(ctx context.Context, f *cliutil.Factory) *cobra.Command {
 		Short: "Manage application on servers",
 	}
 
+	cmd.PersistentFlags().String("role", "", "Role to run command on")
+
 	cmd.AddCommand(showCmd.NewCmdShow(ctx, f))
 	cmd.AddCommand(stopCmd.NewCmdStop(ctx, f))
 	cmd.AddCommand(startCmd.NewCmdStart(ctx, f))
 	cmd.AddCommand(restartCmd.NewCmdRestart(ctx, f))
 	cmd.AddCommand(execCmd.NewCmdExec(ctx, f))
+	cmd.AddCommand(runCmd.NewCmdRun(ctx, f))
 
 	return cmd
 }
@@ -0,0 +1,47 @@
+package run
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/lex-unix/faino/internal/app"
+	"github.com/lex-unix/faino/internal/cli/cliutil"
+)
+
+type RunOptions struct {
+	interactive bool
+	host        string
+	version     string
+}
+
+func NewCmdRun(ctx context.Context, f *cliutil.Factory) *cobra.Command {
+	opts := RunOptions{}
+	cmd := &cobra.Command{
+		Use:       "run",
+		Short:     "Run a one-off command on servers in a new container from the app image",
+		Args:      cobra.ExactArgs(1),
+		ValidArgs: []cobra.Completion{"CMD"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if opts.interactive && opts.host == "" {
+				return fmt.Errorf("--interactive must be used with --host flag")
+			}
+			a, err := f.App()
+			if err != nil {
+				return err
+			}
+
+			return a.RunService(ctx, app.RunOptions{
+				Cmd:         args[0],
+				Version:     opts.version,
+				Interactive: opts.interactive,
+			})
+		},
+	}
+
+	cmd.Flags().BoolVarP(&opts.interactive, "interactive", "i", false, "Start interactive session in the container")
+	cmd.Flags().StringVarP(&opts.host, "host", "H", "", "Run command on specified server")
+	cmd.Flags().StringVar(&opts.version, "version", "", "Version of the app image to run (default: the latest deployed version)")
+
+	return cmd
+}
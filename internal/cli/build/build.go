@@ -0,0 +1,24 @@
+package build
+
+import (
+	"context"
+
+	"github.com/spf13/cobra"
+	"github.com/lex-unix/faino/internal/cli/cliutil"
+	createCmd "github.com/lex-unix/faino/internal/cli/build/create"
+	detailsCmd "github.com/lex-unix/faino/internal/cli/build/details"
+	removeCmd "github.com/lex-unix/faino/internal/cli/build/remove"
+)
+
+func NewCmdBuild(ctx context.Context, f *cliutil.Factory) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "build",
+		Short: "Manage the image builder",
+	}
+
+	cmd.AddCommand(createCmd.NewCmdCreate(ctx, f))
+	cmd.AddCommand(removeCmd.NewCmdRemove(ctx, f))
+	cmd.AddCommand(detailsCmd.NewCmdDetails(ctx, f))
+
+	return cmd
+}
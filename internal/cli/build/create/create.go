@@ -0,0 +1,29 @@
+package create
+
+import (
+	"context"
+
+	"github.com/spf13/cobra"
+	"github.com/lex-unix/faino/internal/cli/cliutil"
+	"github.com/lex-unix/faino/internal/logging"
+)
+
+func NewCmdCreate(ctx context.Context, f *cliutil.Factory) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "create",
+		Short: "Create the image builder, with a node on the remote build host if configured",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			app, err := f.App()
+			if err != nil {
+				return err
+			}
+
+			if err := app.CreateBuilder(ctx); err != nil {
+				return err
+			}
+			logging.Info("builder created")
+			return nil
+		},
+	}
+	return cmd
+}
@@ -0,0 +1,30 @@
+package details
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/lex-unix/faino/internal/cli/cliutil"
+)
+
+func NewCmdDetails(ctx context.Context, f *cliutil.Factory) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "details",
+		Short: "Show the image builder and its nodes",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			app, err := f.App()
+			if err != nil {
+				return err
+			}
+
+			details, err := app.BuilderDetails(ctx)
+			if err != nil {
+				return err
+			}
+			fmt.Print(details)
+			return nil
+		},
+	}
+	return cmd
+}
@@ -0,0 +1,29 @@
+package remove
+
+import (
+	"context"
+
+	"github.com/spf13/cobra"
+	"github.com/lex-unix/faino/internal/cli/cliutil"
+	"github.com/lex-unix/faino/internal/logging"
+)
+
+func NewCmdRemove(ctx context.Context, f *cliutil.Factory) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "remove",
+		Short: "Remove the image builder with all of its nodes",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			app, err := f.App()
+			if err != nil {
+				return err
+			}
+
+			if err := app.RemoveBuilder(ctx); err != nil {
+				return err
+			}
+			logging.Info("builder removed")
+			return nil
+		},
+	}
+	return cmd
+}
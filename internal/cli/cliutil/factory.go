@@ -9,6 +9,7 @@ import (
 	"github.com/lex-unix/faino/internal/config"
 	"github.com/lex-unix/faino/internal/exec/localexec"
 	"github.com/lex-unix/faino/internal/exec/sshexec"
+	"github.com/lex-unix/faino/internal/logging"
 	"github.com/lex-unix/faino/internal/txman"
 )
 
@@ -27,6 +28,9 @@ type Factory struct {
 	Config func() (*config.Config, error)
 	Txman  func() (txman.Service, error)
 	App    func() (*app.App, error)
+
+	// plan records commands instead of running them when --dry-run is set
+	plan *plan
 }
 
 func configFunc() func() (*config.Config, error) {
@@ -60,12 +64,26 @@ func txManFunc(f *Factory) func() (txman.Service, error) {
 		var clients []sshexec.Service
 		for _, host := range hosts {
 			sshClient, err := sshexec.New(host, cfg.SSH.User, cfg.SSH.Port)
+			if cfg.DryRun {
+				// remote state is still read when possible to make the plan accurate
+				var reader sshexec.Service
+				if err == nil {
+					reader = sshClient
+				} else {
+					logging.Warnf("dry run: failed to connect to host %s, remote files will be empty: %s", host, err)
+				}
+				clients = append(clients, f.dryRunPlan().remoteRecorder(host, reader))
+				continue
+			}
 			if err != nil {
 				return nil, fmt.Errorf("failed to connect to host %s: %s", host, err)
 			}
 			clients = append(clients, sshClient)
 		}
 
+		if cfg.DryRun {
+			return f.dryRunPlan().txman(txman.New(clients...)), nil
+		}
 		return txman.New(clients...), nil
 	}
 }
@@ -76,7 +94,10 @@ func appFunc(f *Factory) func() (*app.App, error) {
 		if err != nil {
 			return nil, err
 		}
-		le := localexec.New()
+		var le localexec.Service = localexec.New()
+		if cfg, err := f.Config(); err == nil && cfg.DryRun {
+			le = f.dryRunPlan().localRecorder(le)
+		}
 		return app.New(le, app.WithTxManager(txman)), nil
 	}
 }
@@ -0,0 +1,144 @@
+package cliutil
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/lex-unix/faino/internal/exec/localexec"
+	"github.com/lex-unix/faino/internal/exec/sshexec"
+	"github.com/lex-unix/faino/internal/logging"
+	"github.com/lex-unix/faino/internal/txman"
+)
+
+// plan collects recorders used in dry-run mode.
+type plan struct {
+	mu     sync.Mutex
+	local  *localexec.Recorder
+	remote []*sshexec.Recorder
+}
+
+func (f *Factory) dryRunPlan() *plan {
+	if f.plan == nil {
+		f.plan = &plan{}
+	}
+	return f.plan
+}
+
+func (p *plan) localRecorder(runner localexec.Service) *localexec.Recorder {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.local = localexec.NewRecorder(runner)
+	return p.local
+}
+
+func (p *plan) remoteRecorder(host string, reader sshexec.Service) *sshexec.Recorder {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	recorder := sshexec.NewRecorder(host, reader)
+	p.remote = append(p.remote, recorder)
+	return recorder
+}
+
+// recorderFor returns the recorder of host, or nil if there is none.
+func (p *plan) recorderFor(host string) *sshexec.Recorder {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, recorder := range p.remote {
+		if recorder.Host() == host {
+			return recorder
+		}
+	}
+	return nil
+}
+
+// recordingTxman is a txman.Service that records the rollback steps transactions
+// register with the recorder of their host as soon as they are registered, so that
+// the plan shows what would be undone for every step.
+type recordingTxman struct {
+	txman.Service
+	plan *plan
+}
+
+func (p *plan) txman(m txman.Service) txman.Service {
+	return recordingTxman{Service: m, plan: p}
+}
+
+func (m recordingTxman) BeginTransaction(ctx context.Context, callback txman.TxCallback, opts ...txman.Option) (txman.RollbackFunc, error) {
+	return m.Service.BeginTransaction(ctx, func(ctx context.Context, tx txman.Transaction) error {
+		return callback(ctx, &recordingTx{Transaction: tx, recorder: m.plan.recorderFor(tx.Host())})
+	}, opts...)
+}
+
+func (m recordingTxman) Subset(hosts ...string) (txman.Service, error) {
+	subset, err := m.Service.Subset(hosts...)
+	if err != nil {
+		return nil, err
+	}
+	return m.plan.txman(subset), nil
+}
+
+// recordingTx is a txman.Transaction that records the rollback of every step that succeeded.
+type recordingTx struct {
+	txman.Transaction
+	recorder *sshexec.Recorder
+}
+
+func (tx *recordingTx) Do(ctx context.Context, forwardFn txman.Callback, rollbackFn txman.Callback) error {
+	err := tx.Transaction.Do(ctx, forwardFn, rollbackFn)
+	if err == nil && rollbackFn != nil && tx.recorder != nil {
+		tx.recorder.RecordRollback(func() { _ = rollbackFn(ctx, tx.recorder) })
+	}
+	return err
+}
+
+func (tx *recordingTx) Run(ctx context.Context, forwardCmd string, rollbackCmd string) error {
+	var rollbackFn txman.Callback
+	if rollbackCmd != "" {
+		rollbackFn = func(ctx context.Context, client sshexec.Service) error {
+			return client.Run(ctx, rollbackCmd)
+		}
+	}
+	return tx.Do(ctx, func(ctx context.Context, client sshexec.Service) error {
+		return client.Run(ctx, forwardCmd)
+	}, rollbackFn)
+}
+
+// PrintPlan writes commands recorded in dry-run mode to w.
+// It does nothing if --dry-run was not set.
+func (f *Factory) PrintPlan(w io.Writer) {
+	if f.plan == nil {
+		return
+	}
+	p := f.plan
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var sb strings.Builder
+	sb.WriteString("Dry run, nothing was changed. Planned commands:\n")
+
+	if p.local != nil {
+		sb.WriteString("\nlocal:\n")
+		for i, step := range p.local.Steps() {
+			sb.WriteString(fmt.Sprintf("  %d. %s", i+1, step.Cmd))
+			if step.Executed {
+				sb.WriteString("  (read-only, executed)")
+			}
+			sb.WriteString("\n")
+		}
+	}
+
+	for _, recorder := range p.remote {
+		sb.WriteString(fmt.Sprintf("\n%s:\n", recorder.Host()))
+		for i, step := range recorder.Steps() {
+			sb.WriteString(fmt.Sprintf("  %d. %s\n", i+1, step.Cmd))
+			for _, rollback := range step.Rollback {
+				sb.WriteString(fmt.Sprintf("     rollback: %s\n", rollback))
+			}
+		}
+	}
+
+	fmt.Fprint(w, logging.Redact(sb.String()))
+}
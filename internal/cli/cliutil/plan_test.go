@@ -0,0 +1,33 @@
+package cliutil
+
+import (
+	"context"
+	"testing"
+
+	"github.com/lex-unix/faino/internal/txman"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlanRecordsRollbacks(t *testing.T) {
+	p := &plan{}
+	recorder := p.remoteRecorder("host1", nil)
+	m := p.txman(txman.New(recorder))
+
+	_, err := m.BeginTransaction(context.Background(), func(ctx context.Context, tx txman.Transaction) error {
+		if err := tx.Run(ctx, "command 1", "rollback 1"); err != nil {
+			return err
+		}
+		if err := tx.Run(ctx, "command 2", ""); err != nil {
+			return err
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+
+	steps := recorder.Steps()
+	assert.Len(t, steps, 2)
+	assert.Equal(t, "command 1", steps[0].Cmd)
+	assert.Equal(t, []string{"rollback 1"}, steps[0].Rollback)
+	assert.Equal(t, "command 2", steps[1].Cmd)
+	assert.Empty(t, steps[1].Rollback)
+}
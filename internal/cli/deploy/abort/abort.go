@@ -0,0 +1,31 @@
+package abort
+
+import (
+	"context"
+
+	"github.com/spf13/cobra"
+	"github.com/lex-unix/faino/internal/cli/cliutil"
+	"github.com/lex-unix/faino/internal/logging"
+)
+
+func NewCmdAbort(ctx context.Context, f *cliutil.Factory) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "abort",
+		Short: "Revert the canary servers to the previous version",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			app, err := f.App()
+			if err != nil {
+				return err
+			}
+
+			if err := app.AbortCanary(ctx); err != nil {
+				return err
+			}
+			logging.Info("canary aborted")
+			return nil
+		},
+	}
+
+	return cmd
+}
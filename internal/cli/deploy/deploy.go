@@ -2,29 +2,52 @@ package deploy
 
 import (
 	"context"
+	"errors"
 
 	"github.com/spf13/cobra"
+	"github.com/lex-unix/faino/internal/app"
 	"github.com/lex-unix/faino/internal/cli/cliutil"
+	"github.com/lex-unix/faino/internal/cli/deploy/abort"
+	"github.com/lex-unix/faino/internal/cli/deploy/promote"
 	"github.com/lex-unix/faino/internal/logging"
 )
 
 func NewCmdDeploy(ctx context.Context, f *cliutil.Factory) *cobra.Command {
+	opts := app.DeployOptions{}
 	cmd := &cobra.Command{
 		Use:   "deploy",
 		Short: "Deploy your app to the servers",
+		Args:  cobra.NoArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if opts.PromoteAfter != 0 && len(opts.Canary) == 0 {
+				return errors.New("--promote-after requires --canary")
+			}
+
 			app, err := f.App()
 			if err != nil {
 				return err
 			}
 
-			if err := app.Deploy(ctx); err != nil {
+			if err := app.Deploy(ctx, opts); err != nil {
 				return err
 			}
+			if len(opts.Canary) > 0 && opts.PromoteAfter == 0 {
+				logging.Info("canary deployed, run `faino deploy promote` or `faino deploy abort` to finish the deploy")
+				return nil
+			}
 			logging.Info("app deployed to servers")
 			return nil
 		},
 	}
 
+	cmd.Flags().StringVar(&opts.Version, "version", "", "Deploy under this version instead of the git commit hash")
+	cmd.Flags().BoolVar(&opts.AllowDirty, "allow-dirty", false, "Allow deploying with uncommitted changes, suffixing the version with -dirty")
+
+	cmd.Flags().StringSliceVar(&opts.Canary, "canary", nil, "Deploy only to these hosts as a canary")
+	cmd.Flags().DurationVar(&opts.PromoteAfter, "promote-after", 0, "Promote the canary automatically if it stays healthy for this long")
+
+	cmd.AddCommand(promote.NewCmdPromote(ctx, f))
+	cmd.AddCommand(abort.NewCmdAbort(ctx, f))
+
 	return cmd
 }
@@ -0,0 +1,31 @@
+package promote
+
+import (
+	"context"
+
+	"github.com/spf13/cobra"
+	"github.com/lex-unix/faino/internal/cli/cliutil"
+	"github.com/lex-unix/faino/internal/logging"
+)
+
+func NewCmdPromote(ctx context.Context, f *cliutil.Factory) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "promote",
+		Short: "Deploy the canary version to the remaining servers",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			app, err := f.App()
+			if err != nil {
+				return err
+			}
+
+			if err := app.PromoteCanary(ctx); err != nil {
+				return err
+			}
+			logging.Info("canary promoted to all servers")
+			return nil
+		},
+	}
+
+	return cmd
+}
@@ -0,0 +1,48 @@
+package diff
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/lex-unix/faino/internal/cli/cliutil"
+)
+
+func NewCmdDiff(ctx context.Context, f *cliutil.Factory) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "diff",
+		Short: "Show how env files of the current version on servers differ from the config",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			app, err := f.App()
+			if err != nil {
+				return err
+			}
+
+			diffs, err := app.DiffEnv(ctx)
+			if err != nil {
+				return err
+			}
+
+			for _, diff := range diffs {
+				fmt.Printf("Host %s, container %s:\n", diff.Host, diff.Container)
+				if diff.Empty() {
+					fmt.Println("env file is up to date")
+				}
+				for _, k := range diff.Added {
+					fmt.Printf("+ %s\n", k)
+				}
+				for _, k := range diff.Removed {
+					fmt.Printf("- %s\n", k)
+				}
+				for _, k := range diff.Changed {
+					fmt.Printf("~ %s\n", k)
+				}
+				fmt.Println()
+			}
+
+			return nil
+		},
+	}
+
+	return cmd
+}
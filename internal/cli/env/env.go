@@ -0,0 +1,26 @@
+package env
+
+import (
+	"context"
+
+	"github.com/spf13/cobra"
+	"github.com/lex-unix/faino/internal/cli/cliutil"
+	diffCmd "github.com/lex-unix/faino/internal/cli/env/diff"
+	pushCmd "github.com/lex-unix/faino/internal/cli/env/push"
+	showCmd "github.com/lex-unix/faino/internal/cli/env/show"
+)
+
+func NewCmdEnv(ctx context.Context, f *cliutil.Factory) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "env",
+		Short: "Manage env files of the app on servers",
+	}
+
+	cmd.PersistentFlags().String("role", "", "Role to run command on")
+
+	cmd.AddCommand(pushCmd.NewCmdPush(ctx, f))
+	cmd.AddCommand(showCmd.NewCmdShow(ctx, f))
+	cmd.AddCommand(diffCmd.NewCmdDiff(ctx, f))
+
+	return cmd
+}
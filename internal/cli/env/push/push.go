@@ -0,0 +1,30 @@
+package push
+
+import (
+	"context"
+
+	"github.com/spf13/cobra"
+	"github.com/lex-unix/faino/internal/cli/cliutil"
+	"github.com/lex-unix/faino/internal/logging"
+)
+
+func NewCmdPush(ctx context.Context, f *cliutil.Factory) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "push",
+		Short: "Write env from the config to the env files of the current version on servers",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			app, err := f.App()
+			if err != nil {
+				return err
+			}
+
+			if err := app.PushEnv(ctx); err != nil {
+				return err
+			}
+			logging.Info("env files are written on all servers, restart the app to apply them")
+			return nil
+		},
+	}
+
+	return cmd
+}
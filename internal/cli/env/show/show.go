@@ -0,0 +1,52 @@
+package show
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/spf13/cobra"
+	"github.com/lex-unix/faino/internal/cli/cliutil"
+)
+
+type ShowOptions struct {
+	redacted bool
+}
+
+func NewCmdShow(ctx context.Context, f *cliutil.Factory) *cobra.Command {
+	opts := ShowOptions{}
+	cmd := &cobra.Command{
+		Use:   "show",
+		Short: "Show env files of the current version on servers",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			app, err := f.App()
+			if err != nil {
+				return err
+			}
+
+			files, err := app.ShowEnv(ctx)
+			if err != nil {
+				return err
+			}
+
+			for _, file := range files {
+				fmt.Printf("Host %s, container %s:\n", file.Host, file.Container)
+				for _, k := range slices.Sorted(maps.Keys(file.Env)) {
+					v := file.Env[k]
+					if opts.redacted {
+						v = "[REDACTED]"
+					}
+					fmt.Printf("%s=%s\n", k, v)
+				}
+				fmt.Println()
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&opts.redacted, "redacted", false, "Hide values of variables")
+
+	return cmd
+}
@@ -3,36 +3,129 @@ package history
 import (
 	"context"
 	"fmt"
+	"os"
 	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/lex-unix/faino/internal/app"
 	"github.com/lex-unix/faino/internal/cli/cliutil"
+	syncCmd "github.com/lex-unix/faino/internal/cli/history/sync"
 )
 
+const dateLayout = "2006-01-02 15:04:05"
+
+type HistoryOptions struct {
+	sort   string
+	author string
+	status string
+	since  string
+	until  string
+}
+
 func NewCmdHistory(ctx context.Context, f *cliutil.Factory) *cobra.Command {
+	opts := HistoryOptions{}
 	cmd := &cobra.Command{
 		Use:   "history",
 		Short: "List app version history",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			sortDir, _ := cmd.Flags().GetString("sort")
-			if !slices.Contains([]string{"asc", "desc"}, sortDir) {
-				return fmt.Errorf("sort value can be either 'desc' or 'asc' and you passed: %s", sortDir)
+			if !slices.Contains([]string{"asc", "desc"}, opts.sort) {
+				return fmt.Errorf("sort value can be either 'desc' or 'asc' and you passed: %s", opts.sort)
+			}
+			if opts.status != "" && !slices.Contains(app.HistoryStatuses, opts.status) {
+				return fmt.Errorf("status value can be one of %s and you passed: %s", strings.Join(app.HistoryStatuses, ", "), opts.status)
+			}
+
+			filter := app.HistoryFilter{Author: opts.author, Status: opts.status}
+			var err error
+			if filter.Since, err = parseDate(opts.since); err != nil {
+				return fmt.Errorf("invalid --since value: %w", err)
+			}
+			if filter.Until, err = parseDate(opts.until); err != nil {
+				return fmt.Errorf("invalid --until value: %w", err)
+			}
+			// a plain date includes the whole day
+			if len(opts.until) == len(time.DateOnly) {
+				filter.Until = filter.Until.Add(24*time.Hour - time.Nanosecond)
 			}
+
 			app, err := f.App()
-			history, err := app.History(ctx, sortDir)
+			if err != nil {
+				return err
+			}
+			history, err := app.History(ctx, opts.sort, filter)
 			if err != nil {
 				return err
 			}
 
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "VERSION\tDATE\tSTATUS\tAUTHOR\tBRANCH\tCOMMIT\tDIGEST\tDURATION\tMESSAGE")
 			for _, entry := range history {
-				fmt.Printf("Version: %s, date: %s\n", entry.Version, entry.Timestamp.Format("2006-01-02 15:04:05"))
+				fmt.Fprintf(
+					w,
+					"%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+					entry.Version,
+					entry.Timestamp.Local().Format(dateLayout),
+					entry.Status,
+					orDash(entry.Author),
+					orDash(entry.Branch),
+					orDash(shorten(entry.Commit, 12)),
+					orDash(shorten(entry.ImageDigest, 19)),
+					formatDuration(entry.Duration),
+					orDash(firstLine(entry.Message)),
+				)
 			}
-
-			return nil
+			return w.Flush()
 		},
 	}
 
-	cmd.PersistentFlags().StringP("sort", "s", "desc", "Display history sorted by timestamp in (desc)ending or (asc)ending order.")
+	cmd.AddCommand(syncCmd.NewCmdSync(ctx, f))
+
+	cmd.Flags().StringVarP(&opts.sort, "sort", "s", "desc", "Display history sorted by timestamp in (desc)ending or (asc)ending order.")
+	cmd.Flags().StringVar(&opts.author, "author", "", "Only show deploys performed by author")
+	cmd.Flags().StringVar(&opts.status, "status", "", "Only show deploys with status ("+strings.Join(app.HistoryStatuses, ", ")+")")
+	cmd.Flags().StringVar(&opts.since, "since", "", "Only show deploys since date (e.g. 2025-01-02 or 2025-01-02T13:23:37Z)")
+	cmd.Flags().StringVar(&opts.until, "until", "", "Only show deploys until date (e.g. 2025-01-02 or 2025-01-02T13:23:37Z)")
 
 	return cmd
 }
+
+// parseDate parses RFC 3339 timestamps and plain dates in local time.
+// An empty value returns zero time.
+func parseDate(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation(time.DateOnly, value, time.Local)
+}
+
+func formatDuration(d time.Duration) string {
+	if d == 0 {
+		return "-"
+	}
+	return d.Round(time.Second).String()
+}
+
+func shorten(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
@@ -0,0 +1,44 @@
+package sync
+
+import (
+	"context"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/lex-unix/faino/internal/cli/cliutil"
+	"github.com/lex-unix/faino/internal/logging"
+)
+
+type SyncOptions struct {
+	from string
+}
+
+func NewCmdSync(ctx context.Context, f *cliutil.Factory) *cobra.Command {
+	opts := SyncOptions{}
+	cmd := &cobra.Command{
+		Use:   "sync",
+		Short: "Rewrite diverging history on servers from a single source of truth",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			app, err := f.App()
+			if err != nil {
+				return err
+			}
+
+			synced, err := app.SyncHistory(ctx, opts.from)
+			if err != nil {
+				return err
+			}
+
+			if len(synced) == 0 {
+				logging.Info("history is in sync on all servers")
+				return nil
+			}
+			logging.Infof("history synced on %s", strings.Join(synced, ", "))
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.from, "from", "", "Server to copy history from (defaults to the most up to date server)")
+
+	return cmd
+}
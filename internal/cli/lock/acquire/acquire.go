@@ -0,0 +1,38 @@
+package acquire
+
+import (
+	"context"
+
+	"github.com/spf13/cobra"
+	"github.com/lex-unix/faino/internal/cli/cliutil"
+	"github.com/lex-unix/faino/internal/logging"
+)
+
+type AcquireOptions struct {
+	reason string
+}
+
+func NewCmdAcquire(ctx context.Context, f *cliutil.Factory) *cobra.Command {
+	opts := AcquireOptions{}
+	cmd := &cobra.Command{
+		Use:   "acquire",
+		Short: "Acquire deploy lock on servers",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			app, err := f.App()
+			if err != nil {
+				return err
+			}
+
+			if err := app.AcquireLock(ctx, opts.reason); err != nil {
+				return err
+			}
+			logging.Info("deploy lock acquired on all servers")
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&opts.reason, "message", "m", "", "Reason for holding the lock")
+	cmd.MarkFlagRequired("message")
+
+	return cmd
+}
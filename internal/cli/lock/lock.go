@@ -0,0 +1,24 @@
+package lock
+
+import (
+	"context"
+
+	"github.com/spf13/cobra"
+	"github.com/lex-unix/faino/internal/cli/cliutil"
+	acquireCmd "github.com/lex-unix/faino/internal/cli/lock/acquire"
+	releaseCmd "github.com/lex-unix/faino/internal/cli/lock/release"
+	statusCmd "github.com/lex-unix/faino/internal/cli/lock/status"
+)
+
+func NewCmdLock(ctx context.Context, f *cliutil.Factory) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "lock",
+		Short: "Manage deploy lock on servers",
+	}
+
+	cmd.AddCommand(statusCmd.NewCmdStatus(ctx, f))
+	cmd.AddCommand(acquireCmd.NewCmdAcquire(ctx, f))
+	cmd.AddCommand(releaseCmd.NewCmdRelease(ctx, f))
+
+	return cmd
+}
@@ -0,0 +1,36 @@
+package release
+
+import (
+	"context"
+
+	"github.com/spf13/cobra"
+	"github.com/lex-unix/faino/internal/cli/cliutil"
+	"github.com/lex-unix/faino/internal/logging"
+)
+
+func NewCmdRelease(ctx context.Context, f *cliutil.Factory) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "release",
+		Short: "Release deploy lock on servers",
+		Long:  "Release deploy lock on servers. A lock held by someone else is only released with --force.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// --force is the global flag, which also releases locks held by someone else
+			force, err := cmd.Flags().GetBool("force")
+			if err != nil {
+				return err
+			}
+			app, err := f.App()
+			if err != nil {
+				return err
+			}
+
+			if err := app.ReleaseLock(ctx, force); err != nil {
+				return err
+			}
+			logging.Info("deploy lock released on all servers")
+			return nil
+		},
+	}
+
+	return cmd
+}
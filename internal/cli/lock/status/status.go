@@ -0,0 +1,41 @@
+package status
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/spf13/cobra"
+	"github.com/lex-unix/faino/internal/cli/cliutil"
+)
+
+func NewCmdStatus(ctx context.Context, f *cliutil.Factory) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "Show deploy lock status on servers",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			app, err := f.App()
+			if err != nil {
+				return err
+			}
+
+			locks, err := app.LockStatus(ctx)
+			if err != nil {
+				return err
+			}
+
+			for _, host := range slices.Sorted(maps.Keys(locks)) {
+				if lock := locks[host]; lock != nil {
+					fmt.Printf("Host %s: locked, %s\n", host, lock)
+				} else {
+					fmt.Printf("Host %s: unlocked\n", host)
+				}
+			}
+
+			return nil
+		},
+	}
+
+	return cmd
+}
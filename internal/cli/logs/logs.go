@@ -35,6 +35,7 @@ func NewCmdLogs(ctx context.Context, f *cliutil.Factory) *cobra.Command {
 	cmd.PersistentFlags().BoolVarP(&opts.Follow, "follow", "f", false, "Follow logs on servers")
 	cmd.PersistentFlags().IntVarP(&opts.Lines, "lines", "n", 100, "Number of lines to show from each server")
 	cmd.PersistentFlags().StringVar(&opts.Since, "since", "", "Show lines since timestamp (e.g. 2013-01-02T13:23:37Z) or relative (e.g. 42m for 42 minutes)")
+	cmd.PersistentFlags().String("role", "", "Role to fetch logs from")
 
 	return cmd
 }
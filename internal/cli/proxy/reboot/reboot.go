@@ -0,0 +1,39 @@
+package reboot
+
+import (
+	"context"
+
+	"github.com/spf13/cobra"
+	"github.com/lex-unix/faino/internal/cli/cliutil"
+	"github.com/lex-unix/faino/internal/logging"
+)
+
+type RebootOptions struct {
+	rolling bool
+}
+
+func NewCmdReboot(ctx context.Context, f *cliutil.Factory) *cobra.Command {
+	opts := RebootOptions{}
+	cmd := &cobra.Command{
+		Use:   "reboot",
+		Short: "Recreate proxy container on servers to apply config changes",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			app, err := f.App()
+			if err != nil {
+				return err
+			}
+
+			if err := app.RebootProxy(ctx, opts.rolling); err != nil {
+				return err
+			}
+
+			logging.Info("proxy container rebooted on servers")
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&opts.rolling, "rolling", false, "Reboot in batches configured by deploy.rolling, one host at a time if not configured")
+
+	return cmd
+}
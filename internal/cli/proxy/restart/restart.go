@@ -18,15 +18,9 @@ func NewCmdRestart(ctx context.Context, f *cliutil.Factory) *cobra.Command {
 				return err
 			}
 
-			err = app.StopProxy(ctx)
+			err = app.RestartProxy(ctx)
 			if err != nil {
-				logging.Errorf("failed to stop proxy container: %s", err)
-				return err
-			}
-
-			err = app.StartProxy(ctx)
-			if err != nil {
-				logging.Errorf("failed to start proxy container: %s", err)
+				logging.Errorf("failed to restart proxy container: %s", err)
 				return err
 			}
 
@@ -23,8 +23,18 @@ func NewCmdShow(ctx context.Context, f *cliutil.Factory) *cobra.Command {
 				return err
 			}
 
+			drift, err := app.ProxyDrift(ctx)
+			if err != nil {
+				return err
+			}
+
 			for host, output := range info {
 				fmt.Printf("Host %s:\n%s\n", host, output)
+				if drift[host] {
+					fmt.Println("Proxy config is out of date, run `faino proxy reboot` to apply it")
+				} else {
+					fmt.Println("Proxy config is up to date")
+				}
 			}
 
 			return nil
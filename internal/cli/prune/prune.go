@@ -0,0 +1,66 @@
+package prune
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/spf13/cobra"
+	"github.com/lex-unix/faino/internal/app"
+	"github.com/lex-unix/faino/internal/cli/cliutil"
+)
+
+func NewCmdPrune(ctx context.Context, f *cliutil.Factory) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:       "prune [containers|images|all]",
+		Short:     "Remove containers and images of old versions from servers",
+		Long:      "Remove stopped containers and images of versions outside of the retention window set by prune.retain_containers and prune.retain_images.",
+		Args:      cobra.MatchAll(cobra.MaximumNArgs(1), cobra.OnlyValidArgs),
+		ValidArgs: []cobra.Completion{"containers", "images", "all"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts := app.PruneOptions{Containers: true, Images: true}
+			if len(args) > 0 {
+				opts.Containers = args[0] != "images"
+				opts.Images = args[0] != "containers"
+			}
+
+			a, err := f.App()
+			if err != nil {
+				return err
+			}
+
+			results, err := a.Prune(ctx, opts)
+			if err != nil {
+				return err
+			}
+			if len(results) == 0 {
+				fmt.Println("Nothing to prune")
+				return nil
+			}
+
+			for _, host := range slices.Sorted(maps.Keys(results)) {
+				result := results[host]
+				fmt.Printf("Host %s: removed %d containers and %d images, freed %s\n",
+					host, len(result.Containers), len(result.Images), formatBytes(result.Freed))
+			}
+
+			return nil
+		},
+	}
+
+	return cmd
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -2,35 +2,89 @@ package rollback
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
 
 	"github.com/spf13/cobra"
 	"github.com/lex-unix/faino/internal/cli/cliutil"
+	"github.com/lex-unix/faino/internal/config"
 	"github.com/lex-unix/faino/internal/logging"
 )
 
+type RollbackOptions struct {
+	steps int
+	yes   bool
+}
+
 func NewCmdRollback(ctx context.Context, f *cliutil.Factory) *cobra.Command {
+	opts := RollbackOptions{}
 	cmd := &cobra.Command{
-		Use:   "rollback",
+		Use:   "rollback [VERSION]",
 		Short: "Rollback to your app's desired version",
+		Long:  "Rollback to VERSION, or to the previously deployed version if VERSION is omitted.",
+		Args:  cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if len(args) == 0 {
-				return fmt.Errorf("the version of the app to rollback to is required")
+			if len(args) > 0 && cmd.Flags().Changed("steps") {
+				return errors.New("--steps cannot be used together with a version")
 			}
-			version := args[0]
 
 			app, err := f.App()
 			if err != nil {
 				return err
 			}
 
+			var version string
+			if len(args) > 0 {
+				version = args[0]
+			} else {
+				current, target, err := app.RollbackTarget(ctx, opts.steps)
+				if err != nil {
+					return err
+				}
+				cfg, err := f.Config()
+				if err != nil {
+					return err
+				}
+				question := fmt.Sprintf("Roll back from %s to %s?", current, target)
+				if !opts.yes && !cfg.DryRun && !cliutil.Confirm(os.Stdin, os.Stdout, question) {
+					return errors.New("rollback aborted")
+				}
+				version = target
+			}
+
 			if err := app.Rollback(ctx, version); err != nil {
 				return err
 			}
 			logging.Infof("app rolled back to version %s", version)
 			return nil
 		},
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+			if len(args) > 0 {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+			// config is not loaded for completion requests
+			if _, err := config.Load(cmd.Flags()); err != nil {
+				return nil, cobra.ShellCompDirectiveError
+			}
+			app, err := f.App()
+			if err != nil {
+				return nil, cobra.ShellCompDirectiveError
+			}
+			versions, err := app.DeployedVersions(ctx)
+			if err != nil {
+				return nil, cobra.ShellCompDirectiveError
+			}
+			// the current version is not a rollback target
+			if len(versions) > 0 {
+				versions = versions[1:]
+			}
+			return versions, cobra.ShellCompDirectiveNoFileComp
+		},
 	}
 
+	cmd.Flags().IntVar(&opts.steps, "steps", 1, "Number of versions to go back when no version is given")
+	cmd.Flags().BoolVarP(&opts.yes, "yes", "y", false, "Do not ask for confirmation")
+
 	return cmd
 }
@@ -4,13 +4,18 @@ import (
 	"context"
 	"os"
 
+	accessoryCmd "github.com/lex-unix/faino/internal/cli/accessory"
 	appCmd "github.com/lex-unix/faino/internal/cli/app"
+	buildCmd "github.com/lex-unix/faino/internal/cli/build"
 	"github.com/lex-unix/faino/internal/cli/cliutil"
 	deployCmd "github.com/lex-unix/faino/internal/cli/deploy"
+	envCmd "github.com/lex-unix/faino/internal/cli/env"
 	historyCmd "github.com/lex-unix/faino/internal/cli/history"
 	initCmd "github.com/lex-unix/faino/internal/cli/init"
+	lockCmd "github.com/lex-unix/faino/internal/cli/lock"
 	logsCmd "github.com/lex-unix/faino/internal/cli/logs"
 	proxyCmd "github.com/lex-unix/faino/internal/cli/proxy"
+	pruneCmd "github.com/lex-unix/faino/internal/cli/prune"
 	registryCmd "github.com/lex-unix/faino/internal/cli/registry"
 	rollbackCmd "github.com/lex-unix/faino/internal/cli/rollback"
 	"github.com/lex-unix/faino/internal/config"
@@ -27,6 +32,7 @@ func NewRootCmd(ctx context.Context, f *cliutil.Factory) *cobra.Command {
 		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
 			if cliutil.IsConfigLoadingEnabled(cmd) {
 				if cfg, err := config.Load(cmd.Flags()); err == nil {
+					logging.AddSecrets(cfg.SecretValues()...)
 					if cfg.Debug {
 						logging.SetDefault(logging.New(os.Stderr, logging.LevelDebug))
 					}
@@ -42,14 +48,20 @@ func NewRootCmd(ctx context.Context, f *cliutil.Factory) *cobra.Command {
 	cmd.PersistentFlags().BoolP("debug", "d", false, "Display debugging output in the console")
 	cmd.PersistentFlags().String("host", "", "Host to run command on")
 	cmd.PersistentFlags().Bool("force", false, "Force non-transactional execution")
+	cmd.PersistentFlags().Bool("dry-run", false, "Print commands that would run locally and on servers without executing them")
 
 	cmd.AddCommand(deployCmd.NewCmdDeploy(ctx, f))
 	cmd.AddCommand(rollbackCmd.NewCmdRollback(ctx, f))
 	cmd.AddCommand(historyCmd.NewCmdHistory(ctx, f))
 	cmd.AddCommand(logsCmd.NewCmdLogs(ctx, f))
 	cmd.AddCommand(appCmd.NewCmdApp(ctx, f))
+	cmd.AddCommand(envCmd.NewCmdEnv(ctx, f))
 	cmd.AddCommand(registryCmd.NewCmdRegistry(ctx, f))
+	cmd.AddCommand(buildCmd.NewCmdBuild(ctx, f))
 	cmd.AddCommand(proxyCmd.NewCmdProxy(ctx, f))
+	cmd.AddCommand(accessoryCmd.NewCmdAccessory(ctx, f))
+	cmd.AddCommand(lockCmd.NewCmdLock(ctx, f))
+	cmd.AddCommand(pruneCmd.NewCmdPrune(ctx, f))
 	cmd.AddCommand(initCmd.NewCmdInit(ctx, f))
 
 	return cmd
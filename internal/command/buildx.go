@@ -7,13 +7,21 @@ import (
 
 func BuildImage(
 	img string,
+	builder string,
 	dockerfile string,
 	platform string,
 	secrets map[string]string,
 	buildArgs map[string]string,
+	metadataFile string,
+	push bool,
 ) string {
+	// images that are not pushed are loaded into the local image store
+	output := "--load"
+	if push {
+		output = "--push"
+	}
 	var sb strings.Builder
-	sb.WriteString("docker buildx build --push --builder faino-hybrid -t ")
+	sb.WriteString(fmt.Sprintf("docker buildx build %s --builder %s -t ", output, builder))
 	sb.WriteString(img)
 	sb.WriteString(fmt.Sprintf(" --platform %s", platform))
 	for k := range secrets {
@@ -22,15 +30,35 @@ func BuildImage(
 	for k, v := range buildArgs {
 		sb.WriteString(fmt.Sprintf(" --build-arg %s=%q", k, v))
 	}
+	if metadataFile != "" {
+		sb.WriteString(fmt.Sprintf(" --metadata-file %s", metadataFile))
+	}
 	sb.WriteString(" ")
 	sb.WriteString(dockerfile)
 
 	return sb.String()
 }
-func ListBuilders(builder string) string {
-	return fmt.Sprintf("docker buildx ls")
-}
-
 func CreateBuilder(builder string, driver string, platform string) string {
 	return fmt.Sprintf("docker buildx create --bootstrap --platform %s --name %s --driver %s", platform, builder, driver)
 }
+
+// AppendBuilderNode adds a node named node that builds images for platform on the docker
+// daemon at endpoint, e.g. ssh://user@host, to builder.
+func AppendBuilderNode(builder, node, driver, platform, endpoint string) string {
+	return fmt.Sprintf("docker buildx create --append --bootstrap --name %s --node %s --driver %s --platform %s %s", builder, node, driver, platform, endpoint)
+}
+
+// InspectBuilder prints the nodes of builder and fails if it does not exist.
+func InspectBuilder(builder string) string {
+	return fmt.Sprintf("docker buildx inspect %s", builder)
+}
+
+// RemoveBuilder removes builder with all of its nodes.
+func RemoveBuilder(builder string) string {
+	return fmt.Sprintf("docker buildx rm %s", builder)
+}
+
+// EndpointPlatform prints the platform of the docker daemon at endpoint, e.g. linux/arm64.
+func EndpointPlatform(endpoint string) string {
+	return fmt.Sprintf("docker -H %s version --format '{{.Server.Os}}/{{.Server.Arch}}'", endpoint)
+}
@@ -2,7 +2,9 @@ package command
 
 import (
 	"fmt"
+	"slices"
 	"strings"
+	"time"
 )
 
 func IsDockerInstalled() string {
@@ -25,27 +27,102 @@ func PullImage(img string) string {
 	return fmt.Sprintf("docker pull %s", img)
 }
 
+// SaveImage writes img as a gzip compressed archive to path on the local machine.
+func SaveImage(img, path string) string {
+	return fmt.Sprintf("docker save %s | gzip > %s", img, path)
+}
+
+// LoadImage loads an image archive, compressed or not, from stdin.
+func LoadImage() string {
+	return "docker load"
+}
+
+// ServerPlatform prints the platform of the docker daemon, e.g. linux/amd64.
+func ServerPlatform() string {
+	return "docker version --format '{{.Server.Os}}/{{.Server.Arch}}'"
+}
+
 func StartContainer(img string) string {
 	return fmt.Sprintf("docker start %s", img)
 }
 
-func RunContainer(img, container string, env []string) string {
-	labels := []string{"--label traefik.enable=true", "--label traefik.http.routers.myapp.entrypoints=web", "--label traefik.http.routers.myapp.rule='PathPrefix(`/`)'"}
-	return fmt.Sprintf("docker run -d %s %s --name %s %s", strings.Join(env, " "), strings.Join(labels, " "), container, img)
+// RunContainer runs img detached as container. options are additional,
+// already formatted `docker run` flags. cmd overrides the image command if not empty.
+func RunContainer(img, container string, env []string, labels []string, options []string, cmd string) string {
+	labelFlags := make([]string, 0, len(labels))
+	for _, label := range labels {
+		labelFlags = append(labelFlags, fmt.Sprintf("--label %s", shellQuote(label)))
+	}
+	return strings.TrimSpace(fmt.Sprintf("docker run -d %s %s %s --name %s %s %s", strings.Join(env, " "), strings.Join(labelFlags, " "), strings.Join(options, " "), container, img, cmd))
 }
 
-func StopContainer(container string) string {
-	return fmt.Sprintf("docker stop %s || true", container)
+// HealthFlags returns `docker run` flags that make docker run cmd inside the container
+// to determine its health.
+func HealthFlags(cmd string, interval, timeout time.Duration, retries int, startPeriod time.Duration) []string {
+	flags := []string{
+		fmt.Sprintf("--health-cmd %s", shellQuote(cmd)),
+		fmt.Sprintf("--health-interval %s", interval),
+		fmt.Sprintf("--health-timeout %s", timeout),
+		fmt.Sprintf("--health-retries %d", retries),
+	}
+	if startPeriod > 0 {
+		flags = append(flags, fmt.Sprintf("--health-start-period %s", startPeriod))
+	}
+	return flags
+}
+
+// HTTPHealthCmd returns a command run inside the container that succeeds if
+// path on port responds with status. The container image must provide curl,
+// otherwise the command fails saying so.
+func HTTPHealthCmd(port int, path string, status int, timeout time.Duration) string {
+	return fmt.Sprintf(
+		"command -v curl >/dev/null || { echo 'curl is missing in the image, set healthcheck.command to check health without it'; exit 1; }; "+
+			"test \"$(curl --silent --output /dev/null --write-out '%%{http_code}' --max-time %d http://localhost:%d%s)\" = \"%d\"",
+		max(1, int(timeout.Seconds())),
+		port,
+		path,
+		status,
+	)
+}
+
+// ContainerHealth prints the health status docker reports for container,
+// or "none" if the container has no health check.
+func ContainerHealth(container string) string {
+	return fmt.Sprintf("docker inspect --format '{{if .State.Health}}{{.State.Health.Status}}{{else}}none{{end}}' %s", container)
 }
 
-func LoginToRegistry(user, password, registry string) string {
-	return fmt.Sprintf("docker login -u %s -p %s %s", user, password, registry)
+// ContainerHealthLog prints the results of the latest health checks of container as JSON,
+// or nothing if the container has no health check.
+func ContainerHealthLog(container string) string {
+	return fmt.Sprintf("docker inspect --format '{{if .State.Health}}{{json .State.Health.Log}}{{end}}' %s", container)
 }
 
-func RunProxy(img, labels, args string) string {
+// ContainerState prints the state docker reports for container, e.g. running or exited.
+func ContainerState(container string) string {
+	return fmt.Sprintf("docker inspect --format '{{.State.Status}}' %s", container)
+}
+
+// shellQuote quotes s as a single shell word.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// RemoveStoppedContainer removes container if it exists and is not running.
+func RemoveStoppedContainer(container string) string {
+	return fmt.Sprintf("docker rm %s 2>/dev/null || true", container)
+}
+
+func StopContainer(container string) string {
+	return fmt.Sprintf("docker stop %s || true", container)
+}
+
+// RunProxy runs the proxy container with access to the docker socket. options are
+// additional, already formatted `docker run` flags and args are passed to the proxy.
+func RunProxy(img, container string, options []string, args string) string {
 	return fmt.Sprintf(
-		"docker run -d -p 80:80 --name traefik --volume /var/run/docker.sock:/var/run/docker.sock:ro %s %s --providers.docker --entryPoints.web.address=:80 --accesslog=true %s",
-		labels,
+		"docker run -d --name %s --volume /var/run/docker.sock:/var/run/docker.sock:ro %s %s %s",
+		container,
+		strings.Join(options, " "),
 		img,
 		args,
 	)
@@ -80,8 +157,9 @@ func ContainerLogs(container string, follow bool, lines int, since string) strin
 	return sb.String()
 }
 
-func RegistryLogin(registry, user, password string) string {
-	return fmt.Sprintf("docker login %s -u %s -p %s", registry, user, password)
+// RegistryLogin logs in to registry as user with the password read from stdin.
+func RegistryLogin(registry, user string) string {
+	return fmt.Sprintf("docker login %s -u %s --password-stdin", registry, user)
 }
 
 func RegistryLogout() string {
@@ -100,3 +178,95 @@ func Exec(container string, execCmd string, interactive bool) string {
 	sb.WriteString(execCmd)
 	return sb.String()
 }
+
+// RunOnce runs cmd in a new container from img that is removed once cmd exits.
+// options are additional, already formatted `docker run` flags.
+func RunOnce(img, container string, env []string, options []string, cmd string, interactive bool) string {
+	var sb strings.Builder
+	sb.WriteString("docker run --rm")
+	if interactive {
+		sb.WriteString(" -it")
+	}
+	fmt.Fprintf(&sb, " --name %s", container)
+	for _, flag := range slices.Concat(env, options) {
+		sb.WriteString(" ")
+		sb.WriteString(flag)
+	}
+	sb.WriteString(" ")
+	sb.WriteString(img)
+	sb.WriteString(" ")
+	sb.WriteString(cmd)
+	return sb.String()
+}
+
+// RemoveImage removes img unless a container still uses it.
+func RemoveImage(img string) string {
+	return fmt.Sprintf("docker image rm %s 2>/dev/null || true", img)
+}
+
+// DiskAvailable prints the number of bytes available on the filesystem that holds docker data.
+func DiskAvailable() string {
+	return `df --output=avail -B1 "$(docker info --format '{{.DockerRootDir}}')" | tail -n 1`
+}
+
+// FileExists succeeds if path is a regular file.
+func FileExists(path string) string {
+	return fmt.Sprintf("test -f %s", path)
+}
+
+// RemoveFile removes the file at path if it exists.
+func RemoveFile(path string) string {
+	return fmt.Sprintf("rm -f %s", path)
+}
+
+// PrepareSecretFile creates an empty file at path, and its directory if needed, that only the user can access.
+func PrepareSecretFile(path string) string {
+	return fmt.Sprintf(`mkdir -p -m 700 "$(dirname %s)" && touch %s && chmod 600 %s`, path, path, path)
+}
+
+// CreateNetwork creates docker network unless it already exists.
+func CreateNetwork(network string) string {
+	return fmt.Sprintf("docker network inspect %s >/dev/null 2>&1 || docker network create %s", network, network)
+}
+
+// ConnectNetwork connects container to network unless it is already connected.
+func ConnectNetwork(network, container string) string {
+	return fmt.Sprintf("docker network connect %s %s 2>/dev/null || true", network, container)
+}
+
+// ContainerLabel prints the value of label on container, or nothing if the container does not exist.
+func ContainerLabel(container, label string) string {
+	return fmt.Sprintf("docker inspect --format '{{index .Config.Labels %q}}' %s 2>/dev/null || true", label, container)
+}
+
+// BackupContainer stops container and renames it to backup, if container exists.
+func BackupContainer(container, backup string) string {
+	return fmt.Sprintf(
+		"if docker inspect %s >/dev/null 2>&1; then docker rename %s %s && docker stop %s; fi",
+		container, container, backup, backup,
+	)
+}
+
+// RestoreContainer replaces container with backup created by BackupContainer and starts it, if backup exists.
+func RestoreContainer(container, backup string) string {
+	return fmt.Sprintf(
+		"docker rm -f %s >/dev/null 2>&1; if docker inspect %s >/dev/null 2>&1; then docker rename %s %s && docker start %s; fi",
+		container, backup, backup, container, container,
+	)
+}
+
+// RemoveContainer removes container whether it is running or not.
+func RemoveContainer(container string) string {
+	return fmt.Sprintf("docker rm -f %s 2>/dev/null || true", container)
+}
+
+// RunAccessory runs img detached as container with cmd as its arguments.
+// options are additional, already formatted `docker run` flags.
+func RunAccessory(img, container string, options []string, cmd string) string {
+	return strings.TrimSpace(fmt.Sprintf("docker run -d --name %s %s %s %s", container, strings.Join(options, " "), img, cmd))
+}
+
+// ContainerID prints the ID of container, or nothing if it does not exist.
+func ContainerID(container string) string {
+	return fmt.Sprintf("docker ps --all --quiet --filter name=^/%s$", container)
+}
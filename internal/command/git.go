@@ -9,3 +9,29 @@ func CommitHash() string {
 func CommitMessage() string {
 	return fmt.Sprintf("git log -1 --pretty=%%B")
 }
+
+func WorkingTreeStatus() string {
+	return "git status --porcelain"
+}
+
+// WorkingTreeDiff prints the uncommitted changes to tracked files.
+func WorkingTreeDiff() string {
+	return "git diff HEAD"
+}
+
+// UntrackedFilesHash prints the object hash of the contents of every untracked file that is not ignored.
+func UntrackedFilesHash() string {
+	return "git ls-files --others --exclude-standard -z | xargs -0 git hash-object --"
+}
+
+func FullCommitHash() string {
+	return "git rev-parse HEAD"
+}
+
+func CurrentBranch() string {
+	return "git rev-parse --abbrev-ref HEAD"
+}
+
+func GitUserName() string {
+	return "git config user.name"
+}
@@ -0,0 +1,18 @@
+package command
+
+import "fmt"
+
+// AcquireLock atomically creates lock directory and writes lock details from stdin into it.
+// It fails if the lock directory already exists.
+func AcquireLock(dir, detailsFile string) string {
+	return fmt.Sprintf("mkdir -p $(dirname %s) && mkdir %s && cat > %s/%s", dir, dir, dir, detailsFile)
+}
+
+func ReleaseLock(dir string) string {
+	return fmt.Sprintf("rm -rf %s", dir)
+}
+
+// LockDetails prints lock details or nothing if the lock is not held.
+func LockDetails(dir, detailsFile string) string {
+	return fmt.Sprintf("if [ -d %s ]; then cat %s/%s; fi", dir, dir, detailsFile)
+}
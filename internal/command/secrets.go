@@ -0,0 +1,25 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+)
+
+// OnePasswordRead prints the secret that ref, an op:// reference, points to.
+func OnePasswordRead(ref string) string {
+	return fmt.Sprintf("op read --no-newline %s", shellQuote(ref))
+}
+
+// PassShow prints the password stored at path in the pass password store.
+func PassShow(path string) string {
+	return fmt.Sprintf("pass show %s", shellQuote(path))
+}
+
+// SopsExtract decrypts file with sops and prints the value at the dot-separated key path.
+func SopsExtract(file, key string) string {
+	var extract strings.Builder
+	for part := range strings.SplitSeq(key, ".") {
+		fmt.Fprintf(&extract, "[%q]", part)
+	}
+	return fmt.Sprintf("sops --decrypt --extract %s %s", shellQuote(extract.String()), shellQuote(file))
+}
@@ -5,13 +5,18 @@ import (
 	"fmt"
 	"maps"
 	"os"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/knadh/koanf/parsers/yaml"
 	"github.com/knadh/koanf/providers/env"
 	"github.com/knadh/koanf/providers/file"
 	"github.com/knadh/koanf/providers/posflag"
 	"github.com/knadh/koanf/v2"
+	"github.com/lex-unix/faino/internal/secrets"
 	"github.com/lex-unix/faino/internal/validator"
 	"github.com/spf13/pflag"
 )
@@ -27,24 +32,96 @@ const (
 	driver   = "docker-container"
 
 	// config defaults
-	defaultDockerfilePath = "."
-	defaultSSHPort        = 22
-	defaultSSHUser        = "root"
-	defaultProxyContainer = "traefik"
-	defaultProxyImage     = "traefik:v3.1"
-	defaultRegistryServer = "docker.io"
+	defaultDockerfilePath  = "."
+	defaultSSHPort         = 22
+	defaultSSHUser         = "root"
+	defaultProxyKind       = ProxyKindTraefik
+	defaultProxyEntrypoint = "web"
+	defaultSSLVolume       = "faino-acme"
+	defaultRegistryServer  = "docker.io"
+	defaultRegistryMode    = RegistryModePush
+	defaultDeployMode      = DeployModeStopStart
+	defaultRetainVersions  = 5
+
+	defaultHealthcheckPath     = "/"
+	defaultHealthcheckStatus   = 200
+	defaultHealthcheckTimeout  = 5 * time.Second
+	defaultHealthcheckInterval = time.Second
+	defaultHealthcheckRetries  = 10
 )
 
+// DefaultRole is the role that is routed through the proxy unless configured otherwise
+const DefaultRole = "web"
+
+// Proxy backends
+const (
+	ProxyKindTraefik = "traefik"
+	ProxyKindCaddy   = "caddy"
+)
+
+// ACME challenge types
+const (
+	ChallengeHTTP = "http"
+	ChallengeTLS  = "tls"
+)
+
+// Deploy modes
+const (
+	// DeployModeStopStart stops the running container before starting the new one.
+	DeployModeStopStart = "stop-start"
+	// DeployModeZeroDowntime starts and health-checks the new container
+	// before the running one is stopped.
+	DeployModeZeroDowntime = "zero-downtime"
+)
+
+// Registry modes
+const (
+	// RegistryModePush pushes images to the registry and pulls them on hosts.
+	RegistryModePush = "push"
+	// RegistryModeNone skips the registry and streams images to hosts over ssh.
+	RegistryModeNone = "none"
+)
+
+// defaultProxyImages are the images run for each proxy backend unless proxy.image is set
+var defaultProxyImages = map[string]string{
+	ProxyKindTraefik: "traefik:v3.1",
+	ProxyKindCaddy:   "lucaslorentz/caddy-docker-proxy:2.9",
+}
+
 // Config errors
 var (
 	ErrNotExists = errors.New("config does not exist")
 )
 
 type Proxy struct {
+	Kind      string         `koanf:"kind"`
 	Container string         `koanf:"container"`
 	Img       string         `koanf:"image"`
 	Args      map[string]any `koanf:"args"`
 	Labels    map[string]any `koanf:"labels"`
+	Routing   Routing        `koanf:"routing"`
+	SSL       SSL            `koanf:"ssl"`
+}
+
+// SSL configures automatic HTTPS with certificates issued by an ACME server.
+// CAServer and CACertificates allow using a local ACME server such as Pebble.
+type SSL struct {
+	Enabled        bool   `koanf:"enabled"`
+	Email          string `koanf:"email"`
+	Challenge      string `koanf:"challenge"`
+	CAServer       string `koanf:"ca_server"`
+	CACertificates string `koanf:"ca_certificates"`
+	Volume         string `koanf:"volume"`
+}
+
+// Routing describes how the proxy routes requests to the app container.
+// Requests matching any of Hosts and any of Paths are routed to Port.
+type Routing struct {
+	Hosts       []string `koanf:"hosts"`
+	Paths       []string `koanf:"paths"`
+	Entrypoints []string `koanf:"entrypoints"`
+	Middlewares []string `koanf:"middlewares"`
+	Port        int      `koanf:"port"`
 }
 
 type SSH struct {
@@ -53,6 +130,7 @@ type SSH struct {
 }
 
 type Registry struct {
+	Mode     string `koanf:"mode"`
 	Server   string `koanf:"server"`
 	Username string `koanf:"username"`
 	Password string `koanf:"password"`
@@ -65,25 +143,147 @@ type Transaction struct {
 type Build struct {
 	Dockerfile string            `koanf:"dockerfile"`
 	Args       map[string]string `koanf:"args"`
-	Builder    string
-	Platform   string
-	Driver     string
+	// Remote is an ssh host, e.g. user@host or ssh://user@host, that is added to
+	// the builder as a node building images for its native platform.
+	Remote   string `koanf:"remote"`
+	Builder  string
+	Platform string
+	Driver   string
+}
+
+// Container holds `docker run` options applied to every app container.
+type Container struct {
+	Ports      []string       `koanf:"ports"`
+	Volumes    []string       `koanf:"volumes"`
+	Network    string         `koanf:"network"`
+	Memory     string         `koanf:"memory"`
+	CPUs       string         `koanf:"cpus"`
+	Restart    string         `koanf:"restart"`
+	User       string         `koanf:"user"`
+	ExtraHosts []string       `koanf:"extra_hosts"`
+	Logging    Logging        `koanf:"logging"`
+	Options    map[string]any `koanf:"options"`
+}
+
+// Role is a group of hosts that run the app image with their own command and options,
+// such as web servers and background workers. Container options are applied after
+// the global ones and override them.
+type Role struct {
+	Hosts []string          `koanf:"hosts"`
+	Cmd   string            `koanf:"cmd"`
+	Env   map[string]string `koanf:"env"`
+	// Proxy routes requests to the role through the proxy. It defaults to true for the web role only.
+	Proxy     *bool `koanf:"proxy"`
+	Container `koanf:",squash"`
+}
+
+// Proxied reports whether the role named name is routed through the proxy.
+func (r Role) Proxied(name string) bool {
+	if r.Proxy == nil {
+		return name == DefaultRole
+	}
+	return *r.Proxy
+}
+
+// Accessory is a container that runs next to the app on some of the servers,
+// such as a database or a cache. Accessories are never touched by app deploys.
+type Accessory struct {
+	Image string            `koanf:"image"`
+	Hosts []string          `koanf:"hosts"`
+	Cmd   string            `koanf:"cmd"`
+	Env   map[string]string `koanf:"env"`
+	// Files are uploaded to the hosts and mounted into the container, as local:container paths
+	Files     []string `koanf:"files"`
+	Container `koanf:",squash"`
+}
+
+type Logging struct {
+	Driver  string            `koanf:"driver"`
+	Options map[string]string `koanf:"options"`
+}
+
+// Healthcheck describes how the app container reports its health.
+// Command takes precedence over the HTTP check of Path on Port.
+type Healthcheck struct {
+	Command     string        `koanf:"command"`
+	Path        string        `koanf:"path"`
+	Port        int           `koanf:"port"`
+	Status      int           `koanf:"status"`
+	Timeout     time.Duration `koanf:"timeout"`
+	Interval    time.Duration `koanf:"interval"`
+	Retries     int           `koanf:"retries"`
+	StartPeriod time.Duration `koanf:"start_period"`
+}
+
+// Enabled reports whether the app container should be health checked.
+func (h Healthcheck) Enabled() bool {
+	return h.Command != "" || h.Port > 0
+}
+
+type Rolling struct {
+	BatchSize    int           `koanf:"batch_size"`
+	BatchPercent int           `koanf:"batch_percent"`
+	Pause        time.Duration `koanf:"pause"`
+	MaxFailures  int           `koanf:"max_failures"`
+}
+
+// Enabled reports whether hosts should be updated in batches.
+func (r Rolling) Enabled() bool {
+	return r.BatchSize > 0 || r.BatchPercent > 0
+}
+
+type Deploy struct {
+	Mode    string  `koanf:"mode"`
+	Rolling Rolling `koanf:"rolling"`
+}
+
+// Prune configures how many versions of the app are kept on hosts when old containers
+// and images are pruned after deploys. Zero keeps every version.
+type Prune struct {
+	RetainContainers int `koanf:"retain_containers"`
+	RetainImages     int `koanf:"retain_images"`
+}
+
+// Hook is a command run around deploys and rollbacks. Local commands run on the machine
+// running faino, remote commands run on every host in a one-off container of the app image.
+type Hook struct {
+	Local  string `koanf:"local"`
+	Remote string `koanf:"remote"`
+}
+
+// Hooks lists the hooks for every stage of deploys and rollbacks. Local pre hooks run
+// before remote ones, remote post hooks run before local ones.
+type Hooks struct {
+	PreBuild     []Hook `koanf:"pre_build"`
+	PreDeploy    []Hook `koanf:"pre_deploy"`
+	PostDeploy   []Hook `koanf:"post_deploy"`
+	PreRollback  []Hook `koanf:"pre_rollback"`
+	PostRollback []Hook `koanf:"post_rollback"`
 }
 
 type Config struct {
 	AppName     string
-	Service     string            `koanf:"service"`
-	Image       string            `koanf:"image"`
-	Transaction Transaction       `koanf:"transaction"`
-	Servers     []string          `koanf:"servers"`
-	Host        string            `koanf:"host"`
-	SSH         SSH               `koanf:"ssh"`
-	Registry    Registry          `koanf:"registry"`
-	Proxy       Proxy             `koanf:"proxy"`
-	Build       Build             `koanf:"build"`
-	Debug       bool              `koanf:"debug"`
-	Secrets     map[string]string `koanf:"secrets"`
-	Env         map[string]string `koanf:"env"`
+	Service     string               `koanf:"service"`
+	Image       string               `koanf:"image"`
+	Transaction Transaction          `koanf:"transaction"`
+	Servers     []string             `koanf:"servers"`
+	Host        string               `koanf:"host"`
+	Role        string               `koanf:"role"`
+	Roles       map[string]Role      `koanf:"roles"`
+	SSH         SSH                  `koanf:"ssh"`
+	Registry    Registry             `koanf:"registry"`
+	Proxy       Proxy                `koanf:"proxy"`
+	Build       Build                `koanf:"build"`
+	Deploy      Deploy               `koanf:"deploy"`
+	Hooks       Hooks                `koanf:"hooks"`
+	Prune       Prune                `koanf:"prune"`
+	Container   Container            `koanf:"container"`
+	Accessories map[string]Accessory `koanf:"accessories"`
+	Healthcheck Healthcheck          `koanf:"healthcheck"`
+	Debug       bool                 `koanf:"debug"`
+	DryRun      bool                 `koanf:"dry-run"`
+	Secrets     map[string]string    `koanf:"secrets"`
+	Env         map[string]string    `koanf:"env"`
 }
 
 var k = koanf.New(".")
@@ -92,11 +292,22 @@ func Load(f *pflag.FlagSet) (*Config, error) {
 	k.Set("transaction.bypass", false)
 	k.Set("ssh.port", defaultSSHPort)
 	k.Set("ssh.user", defaultSSHUser)
-	k.Set("proxy.container", defaultProxyContainer)
-	k.Set("proxy.image", defaultProxyImage)
+	k.Set("proxy.kind", defaultProxyKind)
+	k.Set("proxy.routing.entrypoints", []string{defaultProxyEntrypoint})
+	k.Set("proxy.ssl.challenge", ChallengeHTTP)
+	k.Set("proxy.ssl.volume", defaultSSLVolume)
 	k.Set("build.dockerfile", ".")
 	k.Set("registry.server", defaultRegistryServer)
+	k.Set("registry.mode", defaultRegistryMode)
 	k.Set("debug", false)
+	k.Set("deploy.mode", defaultDeployMode)
+	k.Set("prune.retain_containers", defaultRetainVersions)
+	k.Set("prune.retain_images", defaultRetainVersions)
+	k.Set("healthcheck.path", defaultHealthcheckPath)
+	k.Set("healthcheck.status", defaultHealthcheckStatus)
+	k.Set("healthcheck.timeout", defaultHealthcheckTimeout)
+	k.Set("healthcheck.interval", defaultHealthcheckInterval)
+	k.Set("healthcheck.retries", defaultHealthcheckRetries)
 
 	if err := k.Load(file.Provider(fmt.Sprintf("%s.yaml", appName)), yaml.Parser()); err != nil {
 		return nil, err
@@ -128,9 +339,30 @@ func Load(f *pflag.FlagSet) (*Config, error) {
 		return nil, err
 	}
 
+	if cfg.Proxy.Container == "" {
+		cfg.Proxy.Container = cfg.Proxy.Kind
+	}
+	if cfg.Proxy.Img == "" {
+		cfg.Proxy.Img = defaultProxyImages[cfg.Proxy.Kind]
+	}
+
 	cfg.Secrets = expandEnv(cfg.Secrets)
 	cfg.Env = expandEnv(cfg.Env)
 	cfg.Build.Args = expandEnv(cfg.Build.Args)
+	for name, acc := range cfg.Accessories {
+		acc.Env = expandEnv(acc.Env)
+		cfg.Accessories[name] = acc
+	}
+	for name, role := range cfg.Roles {
+		role.Env = expandEnv(role.Env)
+		cfg.Roles[name] = role
+		// servers default to the hosts of every role
+		for _, host := range role.Hosts {
+			if !slices.Contains(cfg.Servers, host) {
+				cfg.Servers = append(cfg.Servers, host)
+			}
+		}
+	}
 
 	if err := validate(); err != nil {
 		return nil, err
@@ -149,8 +381,65 @@ func validate() error {
 	v.Check(cfg.Service != "", "service", "must include service name")
 	v.Check(cfg.Image != "", "image", "must include name of the image")
 	v.Check(len(cfg.Servers) > 0, "servers", "must provide at leat 1 destination server")
-	v.Check(cfg.Registry.Username != "", "registry.username", "must provide registry username")
-	v.Check(cfg.Registry.Password != "", "registry.password", "must provide registry password")
+	v.Check(validator.In(cfg.Registry.Mode, RegistryModePush, RegistryModeNone), "registry.mode", "must be either push or none")
+	if cfg.Registry.Mode != RegistryModeNone {
+		v.Check(cfg.Registry.Username != "", "registry.username", "must provide registry username")
+		v.Check(cfg.Registry.Password != "", "registry.password", "must provide registry password")
+	}
+	v.Check(validator.In(cfg.Deploy.Mode, DeployModeStopStart, DeployModeZeroDowntime), "deploy.mode", "must be either stop-start or zero-downtime")
+	if cfg.Deploy.Mode == DeployModeZeroDowntime {
+		v.Check(cfg.Healthcheck.Port > 0, "healthcheck.port", "must provide container port for zero-downtime deploys")
+	}
+	v.Check(cfg.Deploy.Rolling.BatchSize >= 0, "deploy.rolling.batch_size", "must not be negative")
+	v.Check(cfg.Deploy.Rolling.BatchPercent >= 0 && cfg.Deploy.Rolling.BatchPercent <= 100, "deploy.rolling.batch_percent", "must be between 0 and 100")
+	v.Check(cfg.Deploy.Rolling.BatchSize == 0 || cfg.Deploy.Rolling.BatchPercent == 0, "deploy.rolling", "must set either batch_size or batch_percent")
+	v.Check(cfg.Deploy.Rolling.MaxFailures >= 0, "deploy.rolling.max_failures", "must not be negative")
+	v.Check(cfg.Prune.RetainContainers >= 0, "prune.retain_containers", "must not be negative")
+	v.Check(cfg.Prune.RetainImages >= 0, "prune.retain_images", "must not be negative")
+	v.Check(strings.HasPrefix(cfg.Healthcheck.Path, "/"), "healthcheck.path", "must start with /")
+	v.Check(cfg.Healthcheck.Retries > 0, "healthcheck.retries", "must be greater than zero")
+	v.Check(cfg.Healthcheck.Timeout > 0, "healthcheck.timeout", "must be greater than zero")
+	v.Check(cfg.Healthcheck.Interval > 0, "healthcheck.interval", "must be greater than zero")
+	v.Check(cfg.Healthcheck.StartPeriod >= 0, "healthcheck.start_period", "must not be negative")
+	validateContainer(v, "container", cfg.Container)
+	validateEnv(v, "env", cfg.Env)
+	for name, acc := range cfg.Accessories {
+		validateAccessory(v, name, acc)
+	}
+	for name, role := range cfg.Roles {
+		validateRole(v, name, role)
+	}
+	if len(cfg.Roles) > 0 {
+		var roleHosts []string
+		for _, role := range cfg.Roles {
+			roleHosts = append(roleHosts, role.Hosts...)
+		}
+		for _, host := range cfg.Servers {
+			v.Check(slices.Contains(roleHosts, host), "servers", fmt.Sprintf("host %s is not assigned to any role", host))
+		}
+	}
+	if cfg.Role != "" {
+		_, ok := cfg.Roles[cfg.Role]
+		v.Check(ok, "role", fmt.Sprintf("role %s is not configured", cfg.Role))
+	}
+	v.Check(validator.In(cfg.Proxy.Kind, ProxyKindTraefik, ProxyKindCaddy), "proxy.kind", "must be either traefik or caddy")
+	if cfg.Proxy.Kind == ProxyKindCaddy {
+		v.Check(len(cfg.Proxy.Routing.Middlewares) == 0, "proxy.routing.middlewares", "are not supported by caddy")
+	}
+	validateRouting(v, cfg.Proxy.Routing)
+	validateHooks(v, "hooks.pre_build", cfg.Hooks.PreBuild)
+	validateHooks(v, "hooks.pre_deploy", cfg.Hooks.PreDeploy)
+	validateHooks(v, "hooks.post_deploy", cfg.Hooks.PostDeploy)
+	validateHooks(v, "hooks.pre_rollback", cfg.Hooks.PreRollback)
+	validateHooks(v, "hooks.post_rollback", cfg.Hooks.PostRollback)
+	for _, hook := range cfg.Hooks.PreBuild {
+		v.Check(hook.Remote == "", "hooks.pre_build", "remote hooks are not supported before the image is built")
+	}
+	if cfg.Proxy.SSL.Enabled {
+		v.Check(validator.In(cfg.Proxy.SSL.Challenge, ChallengeHTTP, ChallengeTLS), "proxy.ssl.challenge", "must be either http or tls")
+		v.Check(cfg.Proxy.SSL.Volume != "", "proxy.ssl.volume", "must provide volume to store certificates in")
+		v.Check(len(cfg.Proxy.Routing.Hosts) > 0, "proxy.routing.hosts", "must provide at least 1 host to issue certificates for")
+	}
 
 	if !v.Valid() {
 		return v
@@ -159,10 +448,113 @@ func validate() error {
 	return nil
 }
 
+var (
+	portRx      = regexp.MustCompile(`^((\[[0-9a-fA-F:.]+\]|[0-9.]+):)?(\d+(-\d+)?:)?\d+(-\d+)?(/(tcp|udp|sctp))?$`)
+	memoryRx    = regexp.MustCompile(`^\d+[bkmgBKMG]?$`)
+	restartRx   = regexp.MustCompile(`^(no|always|unless-stopped|on-failure(:\d+)?)$`)
+	extraHostRx = regexp.MustCompile(`^[^:\s]+:\S+$`)
+
+	// accessoryNameRx matches names of accessories and roles, which are part of container names
+	accessoryNameRx = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]*$`)
+)
+
+// validateContainer checks container runtime options found under the key prefix.
+func validateContainer(v *validator.Validator, prefix string, c Container) {
+	for _, port := range c.Ports {
+		v.Check(validator.Matches(port, portRx), prefix+".ports", fmt.Sprintf("invalid port mapping %q", port))
+	}
+	for _, volume := range c.Volumes {
+		v.Check(strings.TrimSpace(volume) != "", prefix+".volumes", "must not contain empty volumes")
+	}
+	v.Check(!strings.ContainsAny(c.Network, " \t"), prefix+".network", "must not contain whitespace")
+	v.Check(c.Memory == "" || validator.Matches(c.Memory, memoryRx), prefix+".memory", "must be a number with an optional b, k, m or g unit")
+	if c.CPUs != "" {
+		cpus, err := strconv.ParseFloat(c.CPUs, 64)
+		v.Check(err == nil && cpus > 0, prefix+".cpus", "must be a positive number")
+	}
+	v.Check(c.Restart == "" || validator.Matches(c.Restart, restartRx), prefix+".restart", "must be one of no, always, unless-stopped or on-failure[:max-retries]")
+	for _, host := range c.ExtraHosts {
+		v.Check(validator.Matches(host, extraHostRx), prefix+".extra_hosts", fmt.Sprintf("invalid extra host %q, must be host:ip", host))
+	}
+	v.Check(len(c.Logging.Options) == 0 || c.Logging.Driver != "", prefix+".logging.driver", "must be set when logging options are provided")
+}
+
+// validateEnv checks that env can be written to docker env files, which do not support multiline values.
+func validateEnv(v *validator.Validator, prefix string, env map[string]string) {
+	for k, value := range env {
+		v.Check(!strings.Contains(value, "\n"), prefix+"."+k, "must not contain newlines")
+	}
+}
+
+func validateRole(v *validator.Validator, name string, role Role) {
+	prefix := "roles." + name
+	validateEnv(v, prefix+".env", role.Env)
+	v.Check(validator.Matches(name, accessoryNameRx), prefix, "name must contain only lowercase letters, digits, '_', '.' and '-'")
+	v.Check(len(role.Hosts) > 0, prefix+".hosts", "must provide at least 1 host")
+	validateContainer(v, prefix, role.Container)
+}
+
+func validateAccessory(v *validator.Validator, name string, acc Accessory) {
+	prefix := "accessories." + name
+	v.Check(validator.Matches(name, accessoryNameRx), prefix, "name must contain only lowercase letters, digits, '_', '.' and '-'")
+	v.Check(acc.Image != "", prefix+".image", "must include name of the image")
+	for _, host := range acc.Hosts {
+		v.Check(slices.Contains(cfg.Servers, host), prefix+".hosts", fmt.Sprintf("host %s is not one of the servers", host))
+	}
+	for _, file := range acc.Files {
+		local, remote, ok := strings.Cut(file, ":")
+		v.Check(ok && local != "" && strings.HasPrefix(remote, "/"), prefix+".files", fmt.Sprintf("invalid file %q, must be local:/container/path", file))
+	}
+	validateContainer(v, prefix, acc.Container)
+}
+
+func validateHooks(v *validator.Validator, key string, hooks []Hook) {
+	for _, hook := range hooks {
+		v.Check((hook.Local == "") != (hook.Remote == ""), key, "each hook must set either local or remote command")
+	}
+}
+
+func validateRouting(v *validator.Validator, r Routing) {
+	for _, host := range r.Hosts {
+		v.Check(host != "" && !strings.ContainsAny(host, "` \t/"), "proxy.routing.hosts", fmt.Sprintf("invalid host %q", host))
+	}
+	for _, path := range r.Paths {
+		v.Check(strings.HasPrefix(path, "/") && !strings.ContainsAny(path, "` \t"), "proxy.routing.paths", fmt.Sprintf("invalid path prefix %q, must start with /", path))
+	}
+	v.Check(len(r.Entrypoints) > 0, "proxy.routing.entrypoints", "must provide at least 1 entrypoint")
+	v.Check(r.Port >= 0 && r.Port <= 65535, "proxy.routing.port", "must be a valid port")
+}
+
 func Get() *Config {
 	return cfg
 }
 
+// sensitiveKeyRx matches env keys whose values are treated as secrets
+var sensitiveKeyRx = regexp.MustCompile(`(?i)(password|passwd|secret|token|api_?key|private|credential|auth)`)
+
+// SecretValues returns the configured values that must not show up in output: the registry
+// password, build secrets and env values with sensitive keys. Secret references are
+// left out, as their values are registered once resolved.
+func (c *Config) SecretValues() []string {
+	values := []string{c.Registry.Password}
+	values = slices.AppendSeq(values, maps.Values(c.Secrets))
+	envs := []map[string]string{c.Env}
+	for _, role := range c.Roles {
+		envs = append(envs, role.Env)
+	}
+	for _, acc := range c.Accessories {
+		envs = append(envs, acc.Env)
+	}
+	for _, env := range envs {
+		for k, v := range env {
+			if sensitiveKeyRx.MatchString(k) {
+				values = append(values, v)
+			}
+		}
+	}
+	return slices.DeleteFunc(values, secrets.IsReference)
+}
+
 func expandEnv(src map[string]string) map[string]string {
 	m := maps.Clone(src)
 	for k, v := range m {
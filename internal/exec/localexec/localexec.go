@@ -83,8 +83,9 @@ func (c Command) Run(ctx context.Context, cmd string, opts ...Option) error {
 		return fmt.Errorf("failed to start command: %q: %w", cmd, err)
 	}
 
-	waitErr := command.Wait()
+	// output must be fully read before Wait closes the pipes
 	wg.Wait()
+	waitErr := command.Wait()
 
 	if waitErr != nil {
 		return fmt.Errorf("failed to execute local command %s: %w", cmd, waitErr)
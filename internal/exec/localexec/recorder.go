@@ -0,0 +1,52 @@
+package localexec
+
+import (
+	"context"
+	"slices"
+	"sync"
+)
+
+// Step is a command recorded by Recorder.
+type Step struct {
+	Cmd string
+	// Executed is set for read-only queries that were run by the wrapped Service.
+	Executed bool
+}
+
+// Recorder is a Service that records commands instead of running them.
+// Commands that capture stdout are treated as read-only queries, like git
+// metadata lookups, and are run by the wrapped Service so that callers get real answers.
+type Recorder struct {
+	runner Service
+	mu     sync.Mutex
+	steps  []Step
+}
+
+func NewRecorder(runner Service) *Recorder {
+	return &Recorder{runner: runner}
+}
+
+func (r *Recorder) Run(ctx context.Context, cmd string, opts ...Option) error {
+	var options runOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	query := options.stdout != nil && r.runner != nil
+
+	r.mu.Lock()
+	r.steps = append(r.steps, Step{Cmd: cmd, Executed: query})
+	r.mu.Unlock()
+
+	if query {
+		return r.runner.Run(ctx, cmd, opts...)
+	}
+	return nil
+}
+
+// Steps returns recorded commands in the order they were run.
+func (r *Recorder) Steps() []Step {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.steps)
+}
@@ -1,6 +1,11 @@
 package sshexec
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/ssh"
+)
 
 type pipeError struct {
 	fd  fd
@@ -33,3 +38,12 @@ func (e CmdNotFoundErr) Error() string {
 func (e CmdNotFoundErr) Unwrap() error {
 	return e.err
 }
+
+// ExitStatus returns the exit status of the remote command that caused err, if err was caused by one.
+func ExitStatus(err error) (int, bool) {
+	var exitErr *ssh.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitStatus(), true
+	}
+	return 0, false
+}
@@ -0,0 +1,92 @@
+package sshexec
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// Step is a remote command recorded by Recorder together with the
+// commands that would roll it back.
+type Step struct {
+	Cmd      string
+	Rollback []string
+}
+
+// Recorder is a Service that records commands instead of running them on the host.
+// Files are read with the wrapped Service if one is set, so that callers see real state.
+type Recorder struct {
+	host   string
+	reader Service
+
+	mu        sync.Mutex
+	steps     []Step
+	rollback  bool
+	rollbacks []string
+}
+
+func NewRecorder(host string, reader Service) *Recorder {
+	return &Recorder{host: host, reader: reader}
+}
+
+func (r *Recorder) Host() string {
+	return r.host
+}
+
+func (r *Recorder) Run(ctx context.Context, cmd string, options ...SessionOption) error {
+	r.record(cmd)
+	return nil
+}
+
+func (r *Recorder) WriteFile(path string, data []byte) error {
+	r.record(fmt.Sprintf("write %d bytes to %s", len(data), path))
+	return nil
+}
+
+func (r *Recorder) ReadFile(path string) ([]byte, error) {
+	if r.reader == nil {
+		return nil, nil
+	}
+	return r.reader.ReadFile(path)
+}
+
+// RecordRollback records commands issued by fn as rollback of the last recorded step.
+func (r *Recorder) RecordRollback(fn func()) {
+	r.mu.Lock()
+	r.rollback = true
+	r.rollbacks = nil
+	r.mu.Unlock()
+
+	fn()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rollback = false
+	if len(r.steps) > 0 {
+		last := &r.steps[len(r.steps)-1]
+		last.Rollback = append(last.Rollback, r.rollbacks...)
+	}
+	r.rollbacks = nil
+}
+
+// Steps returns recorded commands in the order they were run.
+func (r *Recorder) Steps() []Step {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	steps := make([]Step, len(r.steps))
+	for i, step := range r.steps {
+		steps[i] = Step{Cmd: step.Cmd, Rollback: slices.Clone(step.Rollback)}
+	}
+	return steps
+}
+
+func (r *Recorder) record(cmd string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.rollback {
+		r.rollbacks = append(r.rollbacks, cmd)
+		return
+	}
+	r.steps = append(r.steps, Step{Cmd: cmd})
+}
@@ -0,0 +1,96 @@
+// Package sshexectest provides an in-memory sshexec.Service for tests.
+package sshexectest
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"slices"
+	"sync"
+	"unsafe"
+
+	"github.com/lex-unix/faino/internal/exec/sshexec"
+)
+
+// Service is an sshexec.Service that records commands and keeps files in memory.
+// RunFunc, if set, gets the stdin of every command and decides its output and result.
+type Service struct {
+	host    string
+	RunFunc func(ctx context.Context, cmd string, stdin []byte) (string, error)
+
+	mu    sync.Mutex
+	cmds  []string
+	files map[string][]byte
+}
+
+func New(host string) *Service {
+	return &Service{host: host, files: make(map[string][]byte)}
+}
+
+func (s *Service) Run(ctx context.Context, cmd string, options ...sshexec.SessionOption) error {
+	stdinReader, stdoutWriter := streams(options)
+
+	var stdin []byte
+	if stdinReader != nil {
+		data, err := io.ReadAll(stdinReader)
+		if err != nil {
+			return err
+		}
+		stdin = data
+	}
+
+	s.mu.Lock()
+	s.cmds = append(s.cmds, cmd)
+	s.mu.Unlock()
+
+	if s.RunFunc == nil {
+		return nil
+	}
+	out, err := s.RunFunc(ctx, cmd, stdin)
+	if stdoutWriter != nil {
+		if _, writeErr := io.WriteString(stdoutWriter, out); writeErr != nil {
+			return writeErr
+		}
+	}
+	return err
+}
+
+func (s *Service) ReadFile(path string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.files[path]), nil
+}
+
+func (s *Service) WriteFile(path string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.files[path] = slices.Clone(data)
+	return nil
+}
+
+func (s *Service) Host() string {
+	return s.host
+}
+
+// Cmds returns the commands run on the host in order.
+func (s *Service) Cmds() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.cmds)
+}
+
+// streams returns the stdin and stdout set by options.
+// sshexec keeps session options unexported, so they are applied and read through reflection.
+func streams(options []sshexec.SessionOption) (io.Reader, io.Writer) {
+	opts := reflect.New(reflect.TypeOf(sshexec.SessionOption(nil)).In(0).Elem())
+	for _, option := range options {
+		reflect.ValueOf(option).Call([]reflect.Value{opts})
+	}
+	field := func(name string) any {
+		f := opts.Elem().FieldByName(name)
+		return reflect.NewAt(f.Type(), unsafe.Pointer(f.UnsafeAddr())).Elem().Interface()
+	}
+	stdin, _ := field("stdin").(io.Reader)
+	stdout, _ := field("stdout").(io.Writer)
+	return stdin, stdout
+}
@@ -83,35 +83,35 @@ func New(out io.Writer, level Level) *Logger {
 }
 
 func Debug(msg string) {
-	Default().log(LevelDebug, msg)
+	Default().log(LevelDebug, "%s", msg)
 }
 
 func Info(msg string) {
-	Default().log(LevelInfo, msg)
+	Default().log(LevelInfo, "%s", msg)
 }
 
 func Warn(msg string) {
-	Default().log(LevelWarn, msg)
+	Default().log(LevelWarn, "%s", msg)
 }
 
 func Error(msg string) {
-	Default().log(LevelError, msg)
+	Default().log(LevelError, "%s", msg)
 }
 
 func DebugHost(host, msg string) {
-	Default().logWithHost(LevelDebug, host, msg)
+	Default().logWithHost(LevelDebug, host, "%s", msg)
 }
 
 func InfoHost(host, msg string) {
-	Default().logWithHost(LevelInfo, host, msg)
+	Default().logWithHost(LevelInfo, host, "%s", msg)
 }
 
 func WarnHost(host, msg string) {
-	Default().logWithHost(LevelWarn, host, msg)
+	Default().logWithHost(LevelWarn, host, "%s", msg)
 }
 
 func ErrorHost(host, msg string) {
-	Default().logWithHost(LevelError, host, msg)
+	Default().logWithHost(LevelError, host, "%s", msg)
 }
 
 func Debugf(format string, args ...any) {
@@ -156,7 +156,7 @@ func (l *Logger) log(level Level, format string, args ...any) {
 	if level < l.level {
 		return
 	}
-	formattedMsg := fmt.Sprintf(format, args...)
+	formattedMsg := Redact(fmt.Sprintf(format, args...))
 	coloredLevel := level.ColorString()
 	logLine := fmt.Sprintf("%s %s\n", coloredLevel, formattedMsg)
 	l.mu.Lock()
@@ -168,5 +168,5 @@ func (l *Logger) logWithHost(level Level, host string, format string, args ...an
 	formattedMsg := fmt.Sprintf(format, args...)
 	hostPart := fmt.Sprintf("[%s]", blueColor(host))
 	lineWithHost := fmt.Sprintf("%s %s", hostPart, formattedMsg)
-	l.log(level, lineWithHost)
+	l.log(level, "%s", lineWithHost)
 }
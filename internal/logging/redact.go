@@ -0,0 +1,51 @@
+package logging
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// redactedMask replaces secret values in log output
+const redactedMask = "[REDACTED]"
+
+// minSecretLen is the length below which values are not masked, so that short
+// values like "1" or "on" do not mangle unrelated output
+const minSecretLen = 4
+
+var redactor struct {
+	mu       sync.RWMutex
+	secrets  []string
+	replacer *strings.Replacer
+}
+
+// AddSecrets registers values that are masked in all log output from now on.
+func AddSecrets(values ...string) {
+	redactor.mu.Lock()
+	defer redactor.mu.Unlock()
+
+	for _, v := range values {
+		if len(v) >= minSecretLen && !slices.Contains(redactor.secrets, v) {
+			redactor.secrets = append(redactor.secrets, v)
+		}
+	}
+	// longer secrets go first so that a secret containing another one is masked whole
+	slices.SortFunc(redactor.secrets, func(a, b string) int { return cmp.Compare(len(b), len(a)) })
+	oldnew := make([]string, 0, 2*len(redactor.secrets))
+	for _, secret := range redactor.secrets {
+		oldnew = append(oldnew, secret, redactedMask)
+	}
+	redactor.replacer = strings.NewReplacer(oldnew...)
+}
+
+// Redact returns s with every registered secret value masked.
+func Redact(s string) string {
+	redactor.mu.RLock()
+	defer redactor.mu.RUnlock()
+
+	if redactor.replacer == nil {
+		return s
+	}
+	return redactor.replacer.Replace(s)
+}
@@ -0,0 +1,20 @@
+package logging
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedact(t *testing.T) {
+	AddSecrets("hunter2", "hunter2-admin", "on", "")
+
+	assert.Equal(t, "password=[REDACTED] admin=[REDACTED] on", Redact("password=hunter2 admin=hunter2-admin on"))
+
+	var out bytes.Buffer
+	logger := New(&out, LevelDebug)
+	logger.logWithHost(LevelInfo, "host", "running command %q", "echo hunter2 | docker login --password-stdin")
+	assert.NotContains(t, out.String(), "hunter2")
+	assert.Contains(t, out.String(), "echo [REDACTED] | docker login")
+}
@@ -0,0 +1,167 @@
+// Package secrets resolves secret references, such as op://vault/item/field,
+// to their values using external secret managers.
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/lex-unix/faino/internal/command"
+	"github.com/lex-unix/faino/internal/exec/localexec"
+	"github.com/lex-unix/faino/internal/logging"
+)
+
+// Supported reference schemes
+const (
+	SchemeOnePassword = "op"
+	SchemePass        = "pass"
+	SchemeSops        = "sops"
+	SchemeCmd         = "cmd"
+)
+
+// IsReference reports whether value is a reference of one of the supported schemes.
+func IsReference(value string) bool {
+	scheme, _, ok := strings.Cut(value, "://")
+	return ok && slices.Contains([]string{SchemeOnePassword, SchemePass, SchemeSops, SchemeCmd}, scheme)
+}
+
+// Resolver resolves references of one scheme to secret values.
+// ref is the full reference including the scheme, e.g. op://vault/item/field.
+type Resolver interface {
+	Resolve(ctx context.Context, ref string) (string, error)
+}
+
+// ResolverFunc is an adapter to use a function as a Resolver.
+type ResolverFunc func(ctx context.Context, ref string) (string, error)
+
+func (f ResolverFunc) Resolve(ctx context.Context, ref string) (string, error) {
+	return f(ctx, ref)
+}
+
+// Store resolves secret references with the resolver registered for their scheme
+// and caches resolved values in memory, so that every reference is resolved once.
+type Store struct {
+	resolvers map[string]Resolver
+
+	mu    sync.Mutex
+	cache map[string]string
+}
+
+type Option func(*Store)
+
+// WithResolver registers r for references of scheme, replacing the default resolver if any.
+func WithResolver(scheme string, r Resolver) Option {
+	return func(s *Store) {
+		s.resolvers[scheme] = r
+	}
+}
+
+// New returns a Store with resolvers for 1Password CLI, pass, sops and shell commands,
+// which are run with lexec.
+func New(lexec localexec.Service, options ...Option) *Store {
+	s := &Store{
+		resolvers: map[string]Resolver{
+			SchemeOnePassword: onePassword(lexec),
+			SchemePass:        pass(lexec),
+			SchemeSops:        sops(lexec),
+			SchemeCmd:         shellCmd(lexec),
+		},
+		cache: make(map[string]string),
+	}
+	for _, option := range options {
+		option(s)
+	}
+	return s
+}
+
+// Resolve returns the secret value value refers to. Values that are not references
+// to a registered scheme are returned as is.
+func (s *Store) Resolve(ctx context.Context, value string) (string, error) {
+	scheme, _, ok := strings.Cut(value, "://")
+	if !ok {
+		return value, nil
+	}
+	resolver, ok := s.resolvers[scheme]
+	if !ok {
+		return value, nil
+	}
+
+	// the lock is held while resolving, so that a reference is never resolved
+	// twice and secret managers do not prompt for unlocking concurrently
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if secret, ok := s.cache[value]; ok {
+		return secret, nil
+	}
+	secret, err := resolver.Resolve(ctx, value)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve secret %s: %w", value, err)
+	}
+	s.cache[value] = secret
+	logging.AddSecrets(secret)
+	return secret, nil
+}
+
+// ResolveMap returns a copy of m with every value resolved.
+func (s *Store) ResolveMap(ctx context.Context, m map[string]string) (map[string]string, error) {
+	resolved := maps.Clone(m)
+	for k, v := range m {
+		secret, err := s.Resolve(ctx, v)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", k, err)
+		}
+		resolved[k] = secret
+	}
+	return resolved, nil
+}
+
+// output runs cmd with lexec and returns its output without the trailing newline.
+func output(ctx context.Context, lexec localexec.Service, cmd string) (string, error) {
+	var out bytes.Buffer
+	if err := lexec.Run(ctx, cmd, localexec.WithStdout(&out)); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(out.String(), "\n"), nil
+}
+
+// onePassword resolves op://vault/item/field references with the 1Password CLI.
+func onePassword(lexec localexec.Service) Resolver {
+	return ResolverFunc(func(ctx context.Context, ref string) (string, error) {
+		return output(ctx, lexec, command.OnePasswordRead(ref))
+	})
+}
+
+// pass resolves pass://path/to/secret references with the first line stored in pass.
+func pass(lexec localexec.Service) Resolver {
+	return ResolverFunc(func(ctx context.Context, ref string) (string, error) {
+		out, err := output(ctx, lexec, command.PassShow(strings.TrimPrefix(ref, "pass://")))
+		if err != nil {
+			return "", err
+		}
+		secret, _, _ := strings.Cut(out, "\n")
+		return secret, nil
+	})
+}
+
+// sops resolves sops://path/to/file#key.path references by decrypting the file with sops.
+func sops(lexec localexec.Service) Resolver {
+	return ResolverFunc(func(ctx context.Context, ref string) (string, error) {
+		file, key, ok := strings.Cut(strings.TrimPrefix(ref, "sops://"), "#")
+		if !ok || file == "" || key == "" {
+			return "", fmt.Errorf("reference must look like sops://file#key")
+		}
+		return output(ctx, lexec, command.SopsExtract(file, key))
+	})
+}
+
+// shellCmd resolves cmd://command references with the output of the shell command.
+func shellCmd(lexec localexec.Service) Resolver {
+	return ResolverFunc(func(ctx context.Context, ref string) (string, error) {
+		return output(ctx, lexec, strings.TrimPrefix(ref, "cmd://"))
+	})
+}
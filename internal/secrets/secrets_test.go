@@ -0,0 +1,53 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/lex-unix/faino/internal/exec/localexec"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStoreResolve(t *testing.T) {
+	calls := 0
+	fake := ResolverFunc(func(ctx context.Context, ref string) (string, error) {
+		calls++
+		if ref == "op://vault/missing/field" {
+			return "", errors.New("item not found")
+		}
+		return "secret-" + ref[len("op://"):], nil
+	})
+	store := New(localexec.New(), WithResolver(SchemeOnePassword, fake))
+	ctx := context.Background()
+
+	t.Run("resolves and caches references", func(t *testing.T) {
+		env := map[string]string{
+			"DB_PASSWORD": "op://vault/db/password",
+			"API_KEY":     "op://vault/db/password",
+			"LOG_LEVEL":   "debug",
+			"URL":         "https://example.com",
+		}
+		resolved, err := store.ResolveMap(ctx, env)
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]string{
+			"DB_PASSWORD": "secret-vault/db/password",
+			"API_KEY":     "secret-vault/db/password",
+			"LOG_LEVEL":   "debug",
+			"URL":         "https://example.com",
+		}, resolved)
+		assert.Equal(t, "op://vault/db/password", env["DB_PASSWORD"])
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("reports failing reference", func(t *testing.T) {
+		_, err := store.ResolveMap(ctx, map[string]string{"TOKEN": "op://vault/missing/field"})
+		assert.ErrorContains(t, err, "TOKEN: failed to resolve secret op://vault/missing/field")
+	})
+
+	t.Run("resolves shell commands", func(t *testing.T) {
+		secret, err := store.Resolve(ctx, "cmd://printf 's3cret\n'")
+		assert.NoError(t, err)
+		assert.Equal(t, "s3cret", secret)
+	})
+}
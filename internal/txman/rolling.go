@@ -0,0 +1,76 @@
+package txman
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Rolling configures transactions that go through hosts in batches instead of all at once.
+type Rolling struct {
+	// BatchSize is the number of hosts in a batch.
+	BatchSize int
+	// BatchPercent is the size of a batch as a percentage of all hosts.
+	// It is used when BatchSize is zero.
+	BatchPercent int
+	// Pause is the time to wait between batches.
+	Pause time.Duration
+	// MaxFailures is the number of failed hosts tolerated before the whole transaction is aborted.
+	// Failed hosts within the threshold are rolled back on their own.
+	MaxFailures int
+}
+
+// Option configures a single BeginTransaction or Execute call.
+type Option func(*options)
+
+type options struct {
+	rolling Rolling
+}
+
+// WithRolling runs the transaction on hosts in batches described by r.
+func WithRolling(r Rolling) Option {
+	return func(o *options) {
+		o.rolling = r
+	}
+}
+
+// batches splits hosts into consecutive batches. A zero Rolling puts all hosts in one batch.
+func (r Rolling) batches(hosts []string) [][]string {
+	size := r.BatchSize
+	if size == 0 && r.BatchPercent > 0 {
+		// round up so that a batch always has at least one host
+		size = (len(hosts)*r.BatchPercent + 99) / 100
+	}
+	if size <= 0 || size >= len(hosts) {
+		return [][]string{hosts}
+	}
+
+	var batches [][]string
+	for batch := range slices.Chunk(hosts, size) {
+		batches = append(batches, batch)
+	}
+	return batches
+}
+
+// PartialError is returned by a rolling transaction when some hosts failed and
+// were rolled back, but the number of failures stayed within Rolling.MaxFailures.
+// Changes on other hosts were kept.
+type PartialError struct {
+	Errs map[string]error
+}
+
+func (e *PartialError) Error() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("transaction failed and was rolled back on %d host(s):", len(e.Errs)))
+	for _, host := range e.Hosts() {
+		sb.WriteString(fmt.Sprintf("\n  %s: %s", host, e.Errs[host]))
+	}
+	return sb.String()
+}
+
+// Hosts returns sorted names of the failed hosts.
+func (e *PartialError) Hosts() []string {
+	return slices.Sorted(maps.Keys(e.Errs))
+}
@@ -18,6 +18,9 @@ type Transaction interface {
 	// Run is a convenience wrapper around Do for simple command execution.
 	// It assumes a standard way to run a command via sshexec.Service.
 	Run(ctx context.Context, forwardCmd string, rollbackCmd string) error
+
+	// Host returns the host the transaction runs on.
+	Host() string
 }
 
 type transaction struct {
@@ -54,6 +57,10 @@ func (tx *transaction) Do(ctx context.Context, forwardFn Callback, rollbackFn Ca
 	return nil
 }
 
+func (tx *transaction) Host() string {
+	return tx.hostName
+}
+
 func (tx *transaction) Run(ctx context.Context, forwardCmd string, rollbackCmd string) error {
 	var forwardFn Callback = func(ctx context.Context, client sshexec.Service) error {
 		return client.Run(ctx, forwardCmd)
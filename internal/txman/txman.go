@@ -3,7 +3,12 @@ package txman
 import (
 	"context"
 	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/lex-unix/faino/internal/exec/sshexec"
 	"github.com/lex-unix/faino/internal/logging"
@@ -29,24 +34,31 @@ type Service interface {
 	// If transaction succeeded, the returned error is nil and rollback function is nil or no-op
 	// If a command fails or ctx is canceled, returned error is not nil and rollback function can be called
 	// to perform a rollback.
-	BeginTransaction(ctx context.Context, callback TxCallback) (RollbackFunc, error)
+	// With WithRolling, hosts go through the callback in batches and a failed batch
+	// cancels the remaining ones; the rollback function then covers every batch that ran.
+	BeginTransaction(ctx context.Context, callback TxCallback, opts ...Option) (RollbackFunc, error)
 
 	// Execute runs a provided callback on a each remote host.
 	// In case of a command failure, it will continue execution on other hosts.
-	Execute(ctx context.Context, callback Callback) error
+	// With WithRolling, hosts are processed in batches and a failed batch stops the remaining ones.
+	Execute(ctx context.Context, callback Callback, opts ...Option) error
+
+	// Hosts returns sorted names of the remote hosts managed by the service.
+	Hosts() []string
+
+	// Subset returns a Service that manages only the given hosts.
+	// It fails if a host is not managed by the service.
+	Subset(hosts ...string) (Service, error)
 }
 
 type txman struct {
 	// clients stores connections to remote host
 	clients map[string]sshexec.Service
-
-	wg sync.WaitGroup
 }
 
 func New(conns ...sshexec.Service) *txman {
 	m := &txman{
 		clients: make(map[string]sshexec.Service, len(conns)),
-		wg:      sync.WaitGroup{},
 	}
 	for _, conn := range conns {
 		m.clients[conn.Host()] = conn
@@ -55,91 +67,205 @@ func New(conns ...sshexec.Service) *txman {
 	return m
 }
 
-func (m *txman) BeginTransaction(ctx context.Context, callback TxCallback) (RollbackFunc, error) {
-	ctx, cancel := context.WithCancel(ctx)
-	defer cancel()
+func (m *txman) Hosts() []string {
+	return slices.Sorted(maps.Keys(m.clients))
+}
+
+func (m *txman) Subset(hosts ...string) (Service, error) {
+	conns := make([]sshexec.Service, 0, len(hosts))
+	for _, host := range hosts {
+		client, ok := m.clients[host]
+		if !ok {
+			return nil, fmt.Errorf("host %s is not one of the target hosts", host)
+		}
+		conns = append(conns, client)
+	}
+	return New(conns...), nil
+}
+
+func (m *txman) BeginTransaction(ctx context.Context, callback TxCallback, opts ...Option) (RollbackFunc, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	rolling := o.rolling
 
-	txs := make([]*transaction, 0, len(m.clients))
-	for host, client := range m.clients {
-		tx := &transaction{
-			client:   client,
-			hostName: host,
+	// transactions that ran and were not rolled back on their own
+	var txs []*transaction
+	rollbackFn := func(ctx context.Context) error {
+		return rollbackTransactions(ctx, txs)
+	}
+
+	failed := make(map[string]error)
+	batches := rolling.batches(m.Hosts())
+	for i, batch := range batches {
+		if i > 0 && rolling.Pause > 0 {
+			logging.Infof("waiting %s before next batch", rolling.Pause)
+			select {
+			case <-ctx.Done():
+				return rollbackFn, errors.New("transaction cancelled")
+			case <-time.After(rolling.Pause):
+			}
 		}
-		txs = append(txs, tx)
+		if len(batches) > 1 {
+			logging.Infof("running batch %d/%d on %s", i+1, len(batches), strings.Join(batch, ", "))
+		}
+
+		batchTxs := make([]*transaction, 0, len(batch))
+		for _, host := range batch {
+			batchTxs = append(batchTxs, &transaction{client: m.clients[host], hostName: host})
+		}
+
+		batchErrs := m.runBatch(ctx, batchTxs, callback, rolling.MaxFailures-len(failed))
+
+		var txErr error
+		for _, tx := range batchTxs {
+			if err, ok := batchErrs[tx.hostName]; ok {
+				failed[tx.hostName] = err
+				if txErr == nil {
+					txErr = err
+				}
+				continue
+			}
+			txs = append(txs, tx)
+		}
+
+		if len(failed) > rolling.MaxFailures {
+			// failed hosts are rolled back together with the rest of the transaction
+			for _, tx := range batchTxs {
+				if _, ok := batchErrs[tx.hostName]; ok {
+					txs = append(txs, tx)
+				}
+			}
+			return rollbackFn, txErr
+		}
+
+		// failures within the threshold are rolled back right away, the rest carries on
+		for _, tx := range batchTxs {
+			if err, ok := batchErrs[tx.hostName]; ok {
+				logging.ErrorHostf(tx.hostName, "transaction failed, rolling back host: %s", err)
+				if rollbackErr := rollbackTransactions(context.WithoutCancel(ctx), []*transaction{tx}); rollbackErr != nil {
+					failed[tx.hostName] = errors.Join(err, rollbackErr)
+				}
+			}
+		}
+	}
+
+	if len(failed) > 0 {
+		return rollbackFn, &PartialError{Errs: failed}
 	}
 
-	var txErr error
-	var txErrMu sync.Mutex
+	return rollbackFn, nil
+}
+
+// runBatch runs callback on every transaction concurrently and returns errors by host.
+// Once more than tolerated transactions fail, the remaining ones are canceled.
+func (m *txman) runBatch(ctx context.Context, txs []*transaction, callback TxCallback, tolerated int) map[string]error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	errs := make(map[string]error)
 	for _, tx := range txs {
-		m.wg.Add(1)
+		wg.Add(1)
 		go func() {
-			defer m.wg.Done()
+			defer wg.Done()
 			err := callback(ctx, tx)
-			txErrMu.Lock()
-			if err != nil && txErr == nil {
-				txErr = err
+			if err == nil {
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			errs[tx.hostName] = err
+			if len(errs) > tolerated {
 				cancel()
 			}
-			txErrMu.Unlock()
 		}()
 	}
+	wg.Wait()
 
-	m.wg.Wait()
+	return errs
+}
 
-	rollbackFn := func(ctx context.Context) error {
-		var wg sync.WaitGroup
-		rollbackErrCh := make(chan error, len(txs))
-		for _, tx := range txs {
-			if len(tx.rollbackFns) == 0 {
-				continue
-			}
-			wg.Add(1)
-			go func() {
-				defer wg.Done()
-				for i := len(tx.rollbackFns) - 1; i >= 0; i-- {
-					select {
-					case <-ctx.Done():
-						return
-					default:
-					}
-					rollback := tx.rollbackFns[i]
-					err := rollback(ctx, tx.client)
-					if err != nil {
-						rollbackErrCh <- err
-						return
-					}
-				}
-			}()
+// rollbackTransactions executes registered rollback steps of every transaction in reverse order.
+// Transactions are rolled back concurrently.
+func rollbackTransactions(ctx context.Context, txs []*transaction) error {
+	var wg sync.WaitGroup
+	rollbackErrCh := make(chan error, len(txs))
+	for _, tx := range txs {
+		if len(tx.rollbackFns) == 0 {
+			continue
 		}
-
+		wg.Add(1)
 		go func() {
-			wg.Wait()
-			close(rollbackErrCh)
+			defer wg.Done()
+			for i := len(tx.rollbackFns) - 1; i >= 0; i-- {
+				select {
+				case <-ctx.Done():
+					return
+				default:
+				}
+				rollback := tx.rollbackFns[i]
+				err := rollback(ctx, tx.client)
+				if err != nil {
+					rollbackErrCh <- err
+					return
+				}
+			}
 		}()
+	}
 
-		var err error
-		for rollbackErr := range rollbackErrCh {
-			err = errors.Join(err, rollbackErr)
+	go func() {
+		wg.Wait()
+		close(rollbackErrCh)
+	}()
+
+	var err error
+	for rollbackErr := range rollbackErrCh {
+		err = errors.Join(err, rollbackErr)
+	}
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *txman) Execute(ctx context.Context, callback Callback, opts ...Option) error {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	batches := o.rolling.batches(m.Hosts())
+	for i, batch := range batches {
+		if i > 0 && o.rolling.Pause > 0 {
+			logging.Infof("waiting %s before next batch", o.rolling.Pause)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(o.rolling.Pause):
+			}
+		}
+		if len(batches) > 1 {
+			logging.Infof("running batch %d/%d on %s", i+1, len(batches), strings.Join(batch, ", "))
 		}
-		if err != nil {
+		if err := m.execute(ctx, batch, callback); err != nil {
 			return err
 		}
-		return nil
 	}
 
-	if txErr != nil {
-		return rollbackFn, txErr
-	}
-
-	return rollbackFn, nil
+	return nil
 }
 
-func (m *txman) Execute(ctx context.Context, callback Callback) error {
-	errCh := make(chan error, len(m.clients))
-	m.wg.Add(len(m.clients))
-	for host, client := range m.clients {
+func (m *txman) execute(ctx context.Context, hosts []string, callback Callback) error {
+	var wg sync.WaitGroup
+	errCh := make(chan error, len(hosts))
+	wg.Add(len(hosts))
+	for _, host := range hosts {
+		client := m.clients[host]
 		go func() {
-			defer m.wg.Done()
+			defer wg.Done()
 			err := callback(ctx, client)
 			if err != nil {
 				logging.ErrorHost(host, "failed to run command")
@@ -149,7 +275,7 @@ func (m *txman) Execute(ctx context.Context, callback Callback) error {
 	}
 
 	go func() {
-		m.wg.Wait()
+		wg.Wait()
 		close(errCh)
 	}()
 
@@ -10,26 +10,27 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/lex-unix/faino/internal/exec/sshexec"
+	"github.com/lex-unix/faino/internal/exec/sshexec/sshexectest"
 )
 
 func TestBeginTransactin(t *testing.T) {
 	t.Run("runs every command on each host", func(t *testing.T) {
 		var mu sync.Mutex
 		calls := make(map[string][]string)
-		sshClient1 := NewMockSSHLikeService("host1")
-		sshClient1.RunFunc = func(ctx context.Context, cmd string, options ...sshexec.SessionOption) error {
+		sshClient1 := sshexectest.New("host1")
+		sshClient1.RunFunc = func(ctx context.Context, cmd string, stdin []byte) (string, error) {
 			mu.Lock()
 			defer mu.Unlock()
-			calls[sshClient1.hostName] = append(calls[sshClient1.hostName], cmd)
-			return nil
+			calls[sshClient1.Host()] = append(calls[sshClient1.Host()], cmd)
+			return "", nil
 		}
 
-		sshClient2 := NewMockSSHLikeService("host1")
-		sshClient2.RunFunc = func(ctx context.Context, cmd string, options ...sshexec.SessionOption) error {
+		sshClient2 := sshexectest.New("host1")
+		sshClient2.RunFunc = func(ctx context.Context, cmd string, stdin []byte) (string, error) {
 			mu.Lock()
 			defer mu.Unlock()
-			calls[sshClient2.hostName] = append(calls[sshClient1.hostName], cmd)
-			return nil
+			calls[sshClient2.Host()] = append(calls[sshClient1.Host()], cmd)
+			return "", nil
 		}
 
 		m := New(sshClient1, sshClient2)
@@ -48,8 +49,8 @@ func TestBeginTransactin(t *testing.T) {
 			return nil
 		})
 
-		calls1 := calls[sshClient1.hostName]
-		calls2 := calls[sshClient2.hostName]
+		calls1 := calls[sshClient1.Host()]
+		calls2 := calls[sshClient2.Host()]
 
 		assert.NoError(t, err)
 		assert.NotEmpty(t, calls1)
@@ -66,31 +67,31 @@ func TestBeginTransactin(t *testing.T) {
 	t.Run("returns errors if forward pass and rollback pass fail", func(t *testing.T) {
 		// waitCh is used to sync command execution progress between ssh clients
 		waitCh := make(chan struct{})
-		sshClient1 := NewMockSSHLikeService("host1")
-		sshClient1.RunFunc = func(ctx context.Context, cmd string, options ...sshexec.SessionOption) error {
+		sshClient1 := sshexectest.New("host1")
+		sshClient1.RunFunc = func(ctx context.Context, cmd string, stdin []byte) (string, error) {
 			if cmd == "command 2" {
 				select {
 				// wait for sshClient2 to finish executing 'command 1'
 				case <-waitCh:
-					return fmt.Errorf("host %s failed on command: %s", sshClient1.hostName, cmd)
+					return "", fmt.Errorf("host %s failed on command: %s", sshClient1.Host(), cmd)
 				case <-ctx.Done():
-					return ctx.Err()
+					return "", ctx.Err()
 				}
 			}
-			return nil
+			return "", nil
 		}
 
-		sshClient2 := NewMockSSHLikeService("host2")
-		sshClient2.RunFunc = func(ctx context.Context, cmd string, options ...sshexec.SessionOption) error {
+		sshClient2 := sshexectest.New("host2")
+		sshClient2.RunFunc = func(ctx context.Context, cmd string, stdin []byte) (string, error) {
 			if cmd == "command 1" {
 				// signal sshClient1 that 'command 1' has completed
 				close(waitCh)
-				return nil
+				return "", nil
 			}
 			if cmd == "rollback command 1" {
-				return fmt.Errorf("host %s failed rollback command: %s", sshClient2.hostName, cmd)
+				return "", fmt.Errorf("host %s failed rollback command: %s", sshClient2.Host(), cmd)
 			}
-			return nil
+			return "", nil
 		}
 
 		m := New(sshClient1, sshClient2)
@@ -115,15 +116,15 @@ func TestBeginTransactin(t *testing.T) {
 
 	t.Run("executes rollback functions in correct order", func(t *testing.T) {
 		rollbackCmds := make([]string, 0)
-		sshClient := NewMockSSHLikeService("host1")
-		sshClient.RunFunc = func(ctx context.Context, cmd string, options ...sshexec.SessionOption) error {
+		sshClient := sshexectest.New("host1")
+		sshClient.RunFunc = func(ctx context.Context, cmd string, stdin []byte) (string, error) {
 			if cmd == "command 3" {
-				return errors.New("command failed")
+				return "", errors.New("command failed")
 			}
 			if strings.HasPrefix(cmd, "rollback") {
 				rollbackCmds = append(rollbackCmds, cmd)
 			}
-			return nil
+			return "", nil
 		}
 
 		m := New(sshClient)
@@ -150,3 +151,61 @@ func TestBeginTransactin(t *testing.T) {
 		assert.Equal(t, "rollback 1", rollbackCmds[1])
 	})
 }
+
+func TestRollingBatches(t *testing.T) {
+	hosts := []string{"host1", "host2", "host3", "host4", "host5"}
+
+	assert.Equal(t, [][]string{hosts}, Rolling{}.batches(hosts))
+	assert.Equal(t, [][]string{{"host1", "host2"}, {"host3", "host4"}, {"host5"}}, Rolling{BatchSize: 2}.batches(hosts))
+	assert.Equal(t, [][]string{{"host1", "host2"}, {"host3", "host4"}, {"host5"}}, Rolling{BatchPercent: 30}.batches(hosts))
+	assert.Equal(t, [][]string{hosts}, Rolling{BatchSize: 10}.batches(hosts))
+}
+
+func TestRollingTransaction(t *testing.T) {
+	newClients := func(failingHost string, calls *[]string, mu *sync.Mutex) []sshexec.Service {
+		var clients []sshexec.Service
+		for _, host := range []string{"host1", "host2", "host3"} {
+			client := sshexectest.New(host)
+			client.RunFunc = func(ctx context.Context, cmd string, stdin []byte) (string, error) {
+				if host == failingHost && cmd == "command" {
+					return "", errors.New("command failed")
+				}
+				mu.Lock()
+				defer mu.Unlock()
+				*calls = append(*calls, fmt.Sprintf("%s: %s", host, cmd))
+				return "", nil
+			}
+			clients = append(clients, client)
+		}
+		return clients
+	}
+	callback := func(ctx context.Context, tx Transaction) error {
+		return tx.Run(ctx, "command", "rollback")
+	}
+
+	t.Run("failing batch rolls back earlier batches", func(t *testing.T) {
+		var mu sync.Mutex
+		var calls []string
+		m := New(newClients("host2", &calls, &mu)...)
+
+		rollback, err := m.BeginTransaction(context.Background(), callback, WithRolling(Rolling{BatchSize: 1}))
+		assert.Error(t, err)
+		assert.Equal(t, []string{"host1: command"}, calls)
+
+		err = rollback(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"host1: command", "host1: rollback"}, calls)
+	})
+
+	t.Run("failures within threshold are rolled back on their own", func(t *testing.T) {
+		var mu sync.Mutex
+		var calls []string
+		m := New(newClients("host2", &calls, &mu)...)
+
+		_, err := m.BeginTransaction(context.Background(), callback, WithRolling(Rolling{BatchSize: 1, MaxFailures: 1}))
+		var partialErr *PartialError
+		assert.ErrorAs(t, err, &partialErr)
+		assert.Equal(t, []string{"host2"}, partialErr.Hosts())
+		assert.Equal(t, []string{"host1: command", "host3: command"}, calls)
+	})
+}
@@ -83,6 +83,8 @@ func setup(t *testing.T) {
 
 func setupDeployer(t *testing.T) {
 	deployerExec(t, "./setup.sh", "/")
+	// deploys do not create the builder on their own
+	faino(t, "build create")
 }
 
 func waitForHealthy(t *testing.T, maxRetry int, waitTime time.Duration) {